@@ -2,173 +2,318 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/xyaman/anki-tui/models"
 )
 
 // AnkiConnect is the Client for the AnkiConnect API
 type AnkiConnect struct {
-	Url        string
-	Version    int
+	Url     string
+	Key     string
+	Version int
+
+	// Timeout is applied to every request, 0 means no timeout
+	Timeout    time.Duration
 	httpClient *http.Client
 }
 
-func NewAnkiConnect(url string, version int) *AnkiConnect {
+// AnkiConnectError is returned when AnkiConnect answers an action with an error
+type AnkiConnectError struct {
+	Action  string
+	Message string
+}
+
+func (e *AnkiConnectError) Error() string {
+	return fmt.Sprintf("ankiconnect %s: %s", e.Action, e.Message)
+}
+
+// ConnectionError is returned when AnkiConnect could not be reached
+type ConnectionError struct {
+	Action string
+	Err    error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("ankiconnect %s: %v", e.Action, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// IsConnectionError reports whether err means AnkiConnect could not be reached,
+// as opposed to AnkiConnect answering with an error. Errors of other clients
+// (external sources, media URLs) are never connection errors
+func IsConnectionError(err error) bool {
+	var connErr *ConnectionError
+	return errors.As(err, &connErr)
+}
+
+// response is the envelope of every AnkiConnect response
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *string         `json:"error"`
+}
+
+func NewAnkiConnect(url, key string, version int, timeout time.Duration) *AnkiConnect {
 	return &AnkiConnect{
 		Url:        url,
+		Key:        key,
 		Version:    version,
+		Timeout:    timeout,
 		httpClient: &http.Client{},
 	}
 }
 
-// request is a helper function to make a request to the AnkiConnect API, the response needs to be unmarshalled by the caller
-func (c *AnkiConnect) request(action string, params interface{}) ([]byte, error) {
+// request is a helper function to make a request to the AnkiConnect API.
+// If result is not nil, the response result is unmarshalled into it.
+// An error sent by AnkiConnect is returned as *AnkiConnectError
+func (c *AnkiConnect) request(action string, params interface{}, result interface{}) error {
+	return c.requestContext(context.Background(), action, params, result)
+}
+
+// requestContext is like request, but the request is cancelled when ctx is done
+// or the client timeout expires
+func (c *AnkiConnect) requestContext(ctx context.Context, action string, params interface{}, result interface{}) error {
 
-	requestBody, err := json.Marshal(map[string]interface{}{
+	body := map[string]interface{}{
 		"action":  action,
 		"params":  params,
-		"version": 6,
-	})
-
-	if err != nil {
-		return nil, err
+		"version": c.Version,
+	}
+	if c.Key != "" {
+		body["key"] = c.Key
 	}
 
-	resp, err := http.Post(c.Url, "application/json", bytes.NewBuffer(requestBody))
+	requestBody, err := json.Marshal(body)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
 
-	return io.ReadAll(resp.Body)
-}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 
-func (c *AnkiConnect) FindNotesIDByQuery(query string) (*models.FindNotesResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	result, err := c.request("findNotes", map[string]interface{}{
-		"query": query,
-	})
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return &ConnectionError{Action: action, Err: err}
 	}
+	defer resp.Body.Close()
 
-	var notes *models.FindNotesResult
-	err = json.Unmarshal(result, &notes)
+	var res response
+	err = json.NewDecoder(resp.Body).Decode(&res)
 	if err != nil {
-		return nil, err
+		// The connection can be lost while reading the response
+		var netErr net.Error
+		if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+			return &ConnectionError{Action: action, Err: err}
+		}
+		return fmt.Errorf("ankiconnect %s: invalid response: %w", action, err)
 	}
 
-	return notes, nil
+	return res.decode(action, result)
 }
 
-func (c *AnkiConnect) FetchNotesFromID(ids []int) (*models.NotesInfoResult, error) {
-	result, err := c.request("notesInfo", map[string]interface{}{
-		"notes": ids,
-	})
-	if err != nil {
-		return nil, err
+// decode checks the response error and unmarshals the result into v
+func (r *response) decode(action string, v interface{}) error {
+	if r.Error != nil {
+		return &AnkiConnectError{Action: action, Message: *r.Error}
 	}
 
-	var notesInfo *models.NotesInfoResult
-	err = json.Unmarshal(result, &notesInfo)
+	if v == nil || len(r.Result) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(r.Result, v)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("ankiconnect %s: invalid result: %w", action, err)
 	}
 
-	return notesInfo, nil
+	return nil
 }
 
-func (c *AnkiConnect) FetchNotesFromQuery(query string, start int, end int) (*models.NotesInfoResult, error) {
-	notesId, err := c.FindNotesIDByQuery(query)
+func (c *AnkiConnect) FindNotesIDByQuery(query string) (*models.FindNotesResult, error) {
+	var notes models.FindNotesResult
+	err := c.request("findNotes", map[string]interface{}{
+		"query": query,
+	}, &notes.Result)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(notesId.Result) == 0 {
-		return &models.NotesInfoResult{}, nil
-	}
+	return &notes, nil
+}
 
-	if end > len(notesId.Result) {
-		end = len(notesId.Result)
+func (c *AnkiConnect) FetchNotesFromID(ids []int) (*models.NotesInfoResult, error) {
+	var notesInfo models.NotesInfoResult
+	err := c.request("notesInfo", map[string]interface{}{
+		"notes": ids,
+	}, &notesInfo.Result)
+	if err != nil {
+		return nil, err
 	}
 
-	return c.FetchNotesFromID(notesId.Result[start:end])
+	return &notesInfo, nil
 }
 
 func (c *AnkiConnect) DeleteNotes(cards []int) error {
-	_, err := c.request("deleteNotes", map[string]interface{}{
+	return c.request("deleteNotes", map[string]interface{}{
 		"notes": cards,
-	})
-	return err
+	}, nil)
 }
 
-func (c *AnkiConnect) UpdateNoteFields(noteID int, fields models.Fields) error {
-	_, err := c.request("updateNoteFields", map[string]interface{}{
+func updateNoteFieldsParams(noteID int, fields models.Fields) map[string]interface{} {
+	return map[string]interface{}{
 		"note": map[string]interface{}{
 			"id":     noteID,
 			"fields": fields,
 		},
-	})
-	return err
+	}
 }
 
-func (c *AnkiConnect) AddTags(noteID int, tag string) error {
-	_, err := c.request("addTags", map[string]interface{}{
-		"notes": []int{noteID},
-		"tags":  tag,
-	})
+func (c *AnkiConnect) UpdateNoteFields(noteID int, fields models.Fields) error {
+	return c.request("updateNoteFields", updateNoteFieldsParams(noteID, fields), nil)
+}
 
-	return err
+// addTagsParams builds the addTags params, tags are separated by spaces
+func addTagsParams(noteIDs []int, tags string) map[string]interface{} {
+	return map[string]interface{}{
+		"notes": noteIDs,
+		"tags":  tags,
+	}
 }
 
-func (c *AnkiConnect) GetLastAddedCard() (*models.Note, error) {
-	lastNotes, err := c.FindNotesIDByQuery("added:2")
+func (c *AnkiConnect) AddTags(noteIDs []int, tags string) error {
+	return c.request("addTags", addTagsParams(noteIDs, tags), nil)
+}
+
+// AddNote creates a note and returns its ID
+func (c *AnkiConnect) AddNote(note models.NewNote) (int, error) {
+	var noteID int
+	err := c.request("addNote", map[string]interface{}{
+		"note": note,
+	}, &noteID)
+	if err != nil {
+		return 0, err
+	}
+
+	return noteID, nil
+}
+
+// CanAddNotes reports for every note if it can be created,
+// a note can't be created if it's a duplicate or it has an empty first field
+func (c *AnkiConnect) CanAddNotes(notes []models.NewNote) ([]bool, error) {
+	var canAdd []bool
+	err := c.request("canAddNotes", map[string]interface{}{
+		"notes": notes,
+	}, &canAdd)
 	if err != nil {
 		return nil, err
 	}
 
-	lastNoteID := 0
-	for _, currentNoteId := range lastNotes.Result {
-		if currentNoteId > lastNoteID {
-			lastNoteID = currentNoteId
-		}
+	return canAdd, nil
+}
+
+// FetchMiningCandidates returns the newest notes (highest ID first) of the
+// query, at most limit notes. It returns an error if there is no note
+func (c *AnkiConnect) FetchMiningCandidates(query string, limit int) ([]models.Note, error) {
+	res, err := c.FindNotesIDByQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Result) == 0 {
+		return nil, fmt.Errorf("no notes match the minning target query %q", query)
+	}
+
+	// Note IDs are creation timestamps
+	ids := res.Result
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	if len(ids) > limit {
+		ids = ids[:limit]
 	}
 
-	// Get Note Info
-	lastNote, err := c.FetchNotesFromID([]int{lastNoteID})
+	notesInfo, err := c.FetchNotesFromID(ids)
 	if err != nil {
 		return nil, err
 	}
 
-	return &lastNote.Result[0], nil
+	return notesInfo.Result, nil
 }
 
 func (c *AnkiConnect) GuiBrowse(query string) error {
-	_, err := c.request("guiBrowse", map[string]interface{}{
+	return c.request("guiBrowse", map[string]interface{}{
 		"query": query,
-	})
-
-	return err
+	}, nil)
 }
 
-func (c *AnkiConnect) GetMediaDirPath() (string, error) {
-	result, err := c.request("getMediaDirPath", map[string]interface{}{})
+// StoreMediaFile saves data in the collection media folder. An existing file
+// is never overwritten, Anki picks another name instead, so the returned
+// filename is the one that must be used in the fields
+func (c *AnkiConnect) StoreMediaFile(filename string, data []byte) (string, error) {
+	var storedFilename string
+	err := c.request("storeMediaFile", map[string]interface{}{
+		"filename":       filename,
+		"data":           base64.StdEncoding.EncodeToString(data),
+		"deleteExisting": false,
+	}, &storedFilename)
 	if err != nil {
 		return "", err
 	}
 
-	var mediaDirPathResponse map[string]interface{}
-	err = json.Unmarshal(result, &mediaDirPathResponse)
+	return storedFilename, nil
+}
+
+// StoreMediaFileFromURL is like StoreMediaFile, but Anki downloads the file
+func (c *AnkiConnect) StoreMediaFileFromURL(filename string, url string) (string, error) {
+	var storedFilename string
+	err := c.request("storeMediaFile", map[string]interface{}{
+		"filename":       filename,
+		"url":            url,
+		"deleteExisting": false,
+	}, &storedFilename)
 	if err != nil {
 		return "", err
 	}
 
-	mediaDirPath, ok := mediaDirPathResponse["result"].(string)
-	if !ok {
+	return storedFilename, nil
+}
+
+func (c *AnkiConnect) GetMediaDirPath() (string, error) {
+	var mediaDirPath string
+	err := c.request("getMediaDirPath", map[string]interface{}{}, &mediaDirPath)
+	if err != nil {
 		return "", err
 	}
+
 	return mediaDirPath, nil
 }
+
+// Ping returns the AnkiConnect API version, it's used to check that
+// AnkiConnect is reachable and the key is valid
+func (c *AnkiConnect) Ping(ctx context.Context) (int, error) {
+	var version int
+	err := c.requestContext(ctx, "version", map[string]interface{}{}, &version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
@@ -0,0 +1,359 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/effects"
+	"github.com/gopxl/beep/speaker"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// SpeakerSampleRate is the sample rate of the speaker, every clip is
+// resampled to it
+const SpeakerSampleRate = beep.SampleRate(48000)
+
+// Audio plays the notes audio. Remote clips are read from the media cache
+type Audio struct {
+	SampleRate beep.SampleRate
+	cache      *MediaCache
+
+	// err is the speaker initialization error, audio is disabled if it's set
+	err error
+
+	mu     sync.Mutex
+	player *player
+
+	// Playback settings, they are kept between clips
+	speed  float64
+	volume float64
+	loop   bool
+}
+
+func NewAudio(sampleRate beep.SampleRate, cache *MediaCache) *Audio {
+	err := speaker.Init(sampleRate, sampleRate.N(time.Second/2))
+	if err != nil {
+		err = fmt.Errorf("audio is not available: %w", err)
+	}
+
+	return &Audio{
+		SampleRate: sampleRate,
+		cache:      cache,
+		err:        err,
+		speed:      1,
+	}
+}
+
+// open opens the selected note audio. Remote clips are downloaded
+// to the cache the first time
+func (a *Audio) open(note *models.Note) (io.ReadCloser, error) {
+	ref := note.AudioRef()
+	if models.IsRemote(ref) && a.cache != nil {
+		return a.cache.Open(ref)
+	}
+	return note.OpenAudio(App.CollectionPath)
+}
+
+// Playback limits and steps
+const (
+	MinSpeed   = 0.5
+	MaxSpeed   = 2.0
+	SpeedStep  = 0.1
+	MinVolume  = -5.0
+	MaxVolume  = 2.0
+	VolumeStep = 0.5
+
+	// stretchFrameSize is the frame of the time stretch, around 40ms
+	stretchFrameSize = 2048
+)
+
+// player is the clip that is playing. The streamers are only changed
+// while the speaker is locked
+type player struct {
+	note models.Note
+
+	// start and end of the played segment, end is 0 for the whole clip
+	start, end time.Duration
+
+	source  beep.StreamSeekCloser
+	format  beep.Format
+	loop    *loopStreamer
+	stretch *timeStretch
+	volume  *effects.Volume
+	ctrl    *beep.Ctrl
+
+	// done is set by the speaker when the clip ends
+	done atomic.Bool
+}
+
+// PlaybackStatus is the state of the current clip
+type PlaybackStatus struct {
+	// Note and audio reference of the clip
+	NoteID   int
+	AudioRef string
+
+	// Offset is the start of the played segment in the clip,
+	// Position and Duration are relative to the segment
+	Offset time.Duration
+
+	Playing  bool
+	Paused   bool
+	Position time.Duration
+	Duration time.Duration
+	Speed    float64
+	Volume   float64
+	Loop     bool
+}
+
+// Play stops the current clip and plays the note audio
+func (a *Audio) Play(note *models.Note) error {
+	return a.play(note, 0, 0)
+}
+
+// PlaySegment stops the current clip and plays the part of the note audio
+// between start and end
+func (a *Audio) PlaySegment(note *models.Note, start, end time.Duration) error {
+	if end <= start {
+		return errors.New("the segment end must be after the start")
+	}
+	return a.play(note, start, end)
+}
+
+// play plays the note audio, only the part between start and end if end
+// is not 0
+func (a *Audio) play(note *models.Note, start, end time.Duration) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	a.Stop()
+
+	reader, err := a.open(note)
+	if err != nil {
+		return err
+	}
+	if reader == nil {
+		return nil
+	}
+
+	source, format, err := models.DecodeAudio(reader, note.AudioRef())
+	if err != nil {
+		reader.Close()
+		return fmt.Errorf("decoding audio: %w", err)
+	}
+
+	if end > 0 {
+		clip, err := newSegment(source, format.SampleRate.N(start), format.SampleRate.N(end))
+		if err != nil {
+			source.Close()
+			return fmt.Errorf("seeking audio: %w", err)
+		}
+		source = clip
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p := &player{note: *note, start: start, end: end, source: source, format: format}
+	p.loop = &loopStreamer{s: source, loop: a.loop}
+
+	var resampled beep.Streamer = p.loop
+	if format.SampleRate != a.SampleRate {
+		resampled = beep.Resample(4, format.SampleRate, a.SampleRate, p.loop)
+	}
+
+	p.stretch = newTimeStretch(resampled, a.speed, stretchFrameSize)
+	p.volume = &effects.Volume{Streamer: p.stretch, Base: 2, Volume: a.volume, Silent: a.volume <= MinVolume}
+	p.ctrl = &beep.Ctrl{Streamer: p.volume}
+	a.player = p
+
+	// Add a small silence delay
+	silence := beep.Silence(a.SampleRate.N(time.Second / 4))
+
+	speaker.Play(beep.Seq(silence, p.ctrl, beep.Callback(func() {
+		p.done.Store(true)
+		source.Close()
+	})))
+
+	return nil
+}
+
+// Stop stops the current clip. Removing the streamer ends the sequence,
+// so the clip is closed and removed from the speaker
+func (a *Audio) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.player != nil {
+		speaker.Lock()
+		a.player.ctrl.Streamer = nil
+		speaker.Unlock()
+		a.player = nil
+	}
+}
+
+// active returns the current clip if it didn't end. a.mu must be held
+func (a *Audio) active() *player {
+	if a.player == nil || a.player.done.Load() {
+		return nil
+	}
+	return a.player
+}
+
+// TogglePause pauses or resumes the current clip
+func (a *Audio) TogglePause() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p := a.active(); p != nil {
+		speaker.Lock()
+		p.ctrl.Paused = !p.ctrl.Paused
+		speaker.Unlock()
+	}
+}
+
+// Replay plays the last clip again from the start
+func (a *Audio) Replay() error {
+	a.mu.Lock()
+	p := a.player
+	if p == nil {
+		a.mu.Unlock()
+		return nil
+	}
+
+	if p.done.Load() {
+		a.mu.Unlock()
+		note := p.note
+		return a.play(&note, p.start, p.end)
+	}
+	defer a.mu.Unlock()
+
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	p.ctrl.Paused = false
+	return p.seek(0)
+}
+
+// Seek moves the current clip by offset, clamped to the clip
+func (a *Audio) Seek(offset time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p := a.active()
+	if p == nil {
+		return nil
+	}
+
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	position := p.source.Position() + p.format.SampleRate.N(offset)
+	return p.seek(max(0, min(position, p.source.Len()-1)))
+}
+
+// seek moves the source to position. The speaker must be locked
+func (p *player) seek(position int) error {
+	err := p.source.Seek(position)
+	if err != nil {
+		return fmt.Errorf("seeking audio: %w", err)
+	}
+	p.stretch.reset()
+	return nil
+}
+
+// ChangeSpeed changes the playback speed by delta, the pitch is kept
+func (a *Audio) ChangeSpeed(delta float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Round to the step so repeated changes don't accumulate errors
+	a.speed = math.Round((a.speed+delta)/SpeedStep) * SpeedStep
+	a.speed = max(MinSpeed, min(a.speed, MaxSpeed))
+
+	if p := a.active(); p != nil {
+		speaker.Lock()
+		p.stretch.speed = a.speed
+		speaker.Unlock()
+	}
+}
+
+// ChangeVolume changes the volume by delta, the volume is a power of 2
+// so every step is heard the same. At MinVolume the audio is muted
+func (a *Audio) ChangeVolume(delta float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.volume = max(MinVolume, min(a.volume+delta, MaxVolume))
+
+	if p := a.active(); p != nil {
+		speaker.Lock()
+		p.volume.Volume = a.volume
+		p.volume.Silent = a.volume <= MinVolume
+		speaker.Unlock()
+	}
+}
+
+// ToggleLoop enables or disables playing the clip again when it ends
+func (a *Audio) ToggleLoop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.loop = !a.loop
+
+	if p := a.active(); p != nil {
+		speaker.Lock()
+		p.loop.loop = a.loop
+		speaker.Unlock()
+	}
+}
+
+// Status returns the state of the current clip
+func (a *Audio) Status() PlaybackStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := PlaybackStatus{Speed: a.speed, Volume: a.volume, Loop: a.loop}
+	p := a.player
+	if p == nil {
+		return status
+	}
+
+	status.NoteID = p.note.NoteID
+	status.AudioRef = p.note.AudioRef()
+	status.Offset = p.start
+
+	speaker.Lock()
+	status.Paused = p.ctrl.Paused
+	status.Position = p.format.SampleRate.D(p.source.Position())
+	status.Duration = p.format.SampleRate.D(p.source.Len())
+	speaker.Unlock()
+
+	status.Playing = !p.done.Load()
+	if !status.Playing {
+		status.Position = status.Duration
+	}
+	return status
+}
+
+// Prefetch downloads the remote audio of the notes to the cache
+func (a *Audio) Prefetch(notes ...*models.Note) {
+	if a.cache == nil {
+		return
+	}
+
+	for _, note := range notes {
+		ref := note.AudioRef()
+		if models.IsRemote(ref) {
+			// Errors are shown when the clip is played
+			a.cache.Path(ref)
+		}
+	}
+}
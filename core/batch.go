@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// Batch queues AnkiConnect actions and sends all of them in a single
+// request using the "multi" action
+type Batch struct {
+	client  *AnkiConnect
+	actions []batchAction
+}
+
+type batchAction struct {
+	Action  string      `json:"action"`
+	Params  interface{} `json:"params"`
+	Version int         `json:"version"`
+
+	// result is where the action result is unmarshalled, it can be nil
+	result interface{}
+}
+
+func (c *AnkiConnect) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// Add queues an action. If result is not nil, the action result is
+// unmarshalled into it after Send
+func (b *Batch) Add(action string, params interface{}, result interface{}) *Batch {
+	b.actions = append(b.actions, batchAction{
+		Action:  action,
+		Params:  params,
+		Version: b.client.Version,
+		result:  result,
+	})
+	return b
+}
+
+func (b *Batch) Len() int {
+	return len(b.actions)
+}
+
+// Send sends all the queued actions in one request. The errors of each
+// action are returned joined, every one of them as *AnkiConnectError
+func (b *Batch) Send() error {
+	if len(b.actions) == 0 {
+		return nil
+	}
+
+	var results []response
+	err := b.client.request("multi", map[string]interface{}{
+		"actions": b.actions,
+	}, &results)
+	if err != nil {
+		return err
+	}
+
+	if len(results) != len(b.actions) {
+		return fmt.Errorf("ankiconnect multi: expected %d results, got %d", len(b.actions), len(results))
+	}
+
+	var errs []error
+	for i, action := range b.actions {
+		err := results[i].decode(action.Action, action.result)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	b.actions = nil
+	return errors.Join(errs...)
+}
+
+func (b *Batch) UpdateNoteFields(noteID int, fields models.Fields) *Batch {
+	return b.Add("updateNoteFields", updateNoteFieldsParams(noteID, fields), nil)
+}
+
+func (b *Batch) AddTags(noteIDs []int, tags string) *Batch {
+	return b.Add("addTags", addTagsParams(noteIDs, tags), nil)
+}
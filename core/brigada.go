@@ -0,0 +1,363 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// BrigadaBaseURL is the default API url of BrigadaSOS
+const BrigadaBaseURL = "https://api.brigadasos.xyz/api/v1"
+
+// brigadaPageSize is the number of sentences of every request when
+// limit isn't set
+const brigadaPageSize = 100
+
+func init() {
+	RegisterSource("brigadasos", func(config SourceConfig) (ExternalSource, error) {
+		if config.ApiKey == "" {
+			return nil, errors.New("apiKey is empty, set it in the brigadasos source of config.yaml")
+		}
+		return NewBrigadaSource(config)
+	})
+}
+
+type BrigadaSource struct {
+	urlMedia
+
+	http    http.Client
+	apiKey  string
+	baseURL string
+	limit   int
+
+	// Filters of every search, set in options
+	exactMatch bool
+	season     []int
+	episode    []int
+
+	// The API pages with cursors, the cursor of every position already
+	// reached is saved to continue from it. Only the cursors of the last
+	// query are kept
+	mu      sync.Mutex
+	cursors map[brigadaPosition]json.RawMessage
+	query   string
+}
+
+// brigadaPosition is a position in the results of a query
+type brigadaPosition struct {
+	query    string
+	position int
+}
+
+// brigadaRequest is the body of a sentence search
+type brigadaRequest struct {
+	Query       string          `json:"query"`
+	ExactMatch  int             `json:"exact_match"`
+	Limit       int             `json:"limit"`
+	ContentSort *string         `json:"content_sort"`
+	RandomSeed  *int            `json:"random_seed"`
+	Season      []int           `json:"season"`
+	Episode     []int           `json:"episode"`
+	Cursor      json.RawMessage `json:"cursor,omitempty"`
+}
+
+type BrigadaSOSResponse struct {
+	Sentences []Sentence `json:"sentences"`
+
+	// Cursor of the next page, null in the last one
+	Cursor json.RawMessage `json:"cursor"`
+}
+
+type Sentence struct {
+	BasicInfo   BasicInfo   `json:"basic_info"`
+	SegmentInfo SegmentInfo `json:"segment_info"`
+	MediaInfo   MediaInfo   `json:"media_info"`
+}
+
+type BasicInfo struct {
+	NameAnimeJp string `json:"name_anime_jp"`
+	NameAnimeEn string `json:"name_anime_en"`
+}
+
+type SegmentInfo struct {
+	ContentJp string `json:"content_jp"`
+	IsNsfw    bool   `json:"is_nsfw"`
+	ActorJa   string `json:"actor_ja"`
+	ActorEn   string `json:"actor_en"`
+	ActorEs   string `json:"actor_es"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+type MediaInfo struct {
+	PathImage string `json:"path_image"`
+	PathAudio string `json:"path_audio"`
+	PathVideo string `json:"path_video"`
+}
+
+// NewBrigadaSource creates the source, the filters are set in options:
+// exactMatch (true or false), season and episode (numbers separated by
+// commas)
+func NewBrigadaSource(config SourceConfig) (*BrigadaSource, error) {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = BrigadaBaseURL
+	}
+
+	b := &BrigadaSource{
+		urlMedia: urlMedia{imageExt: ".webp", audioExt: ".mp3"},
+		http:     http.Client{},
+		apiKey:   config.ApiKey,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		limit:    config.Limit,
+		cursors:  map[brigadaPosition]json.RawMessage{},
+	}
+
+	var err error
+	if value := config.Options["exactMatch"]; value != "" {
+		b.exactMatch, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("options.exactMatch: %w", err)
+		}
+	}
+	b.season, err = parseIntList(config.Options["season"])
+	if err != nil {
+		return nil, fmt.Errorf("options.season: %w", err)
+	}
+	b.episode, err = parseIntList(config.Options["episode"])
+	if err != nil {
+		return nil, fmt.Errorf("options.episode: %w", err)
+	}
+
+	return b, nil
+}
+
+// parseIntList parses numbers separated by commas, an empty value is nil
+func parseIntList(value string) ([]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	numbers := []int{}
+	for _, field := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+func (b *BrigadaSource) Name() string {
+	return "brigadasos"
+}
+
+func (b *BrigadaSource) DisplayName() string {
+	return "BrigadaSOS"
+}
+
+func (b *BrigadaSource) Capabilities() Capabilities {
+	return HasImages | HasAudio | CanDownload
+}
+
+func (b *BrigadaSource) FetchNotesFromQuery(ctx context.Context, query string, start, end int) ([]models.Note, error) {
+	cursor, more, err := b.seek(ctx, query, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// There are start sentences or less
+	if !more {
+		return []models.Note{}, nil
+	}
+
+	position := start
+
+	sentences := []Sentence{}
+	for count := end - start + 1; count > 0; {
+		page, next, err := b.page(ctx, query, cursor, min(count, b.pageSize()))
+		if err != nil {
+			return nil, err
+		}
+
+		position += len(page)
+		count -= len(page)
+		b.saveCursor(query, position, next)
+
+		sentences = append(sentences, page...)
+
+		if len(page) == 0 || isLastCursor(next) {
+			break
+		}
+		cursor = next
+	}
+
+	notes := make([]models.Note, len(sentences))
+	for i, sentence := range sentences {
+		notes[i] = b.note(sentence)
+		notes[i].NoteID = start + i
+	}
+
+	return notes, nil
+}
+
+func (b *BrigadaSource) pageSize() int {
+	if b.limit > 0 {
+		return b.limit
+	}
+	return brigadaPageSize
+}
+
+// seek returns the cursor of start, more is false if the API has no
+// results after the last position before it. Positions without a saved
+// cursor are reached paging from the closest saved one
+func (b *BrigadaSource) seek(ctx context.Context, query string, start int) (json.RawMessage, bool, error) {
+	b.mu.Lock()
+	position := 0
+	var cursor json.RawMessage
+	for key, c := range b.cursors {
+		if key.query == query && key.position <= start && key.position > position {
+			position, cursor = key.position, c
+		}
+	}
+	b.mu.Unlock()
+
+	for position < start {
+		page, next, err := b.page(ctx, query, cursor, min(start-position, b.pageSize()))
+		if err != nil {
+			return nil, false, err
+		}
+
+		position += len(page)
+		b.saveCursor(query, position, next)
+		if len(page) == 0 || isLastCursor(next) {
+			return nil, false, nil
+		}
+		cursor = next
+	}
+
+	return cursor, true, nil
+}
+
+func (b *BrigadaSource) saveCursor(query string, position int, cursor json.RawMessage) {
+	if isLastCursor(cursor) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if query != b.query {
+		b.query = query
+		clear(b.cursors)
+	}
+	b.cursors[brigadaPosition{query: query, position: position}] = cursor
+}
+
+func isLastCursor(cursor json.RawMessage) bool {
+	return len(cursor) == 0 || string(cursor) == "null"
+}
+
+// page requests up to limit sentences from cursor, nil is the first page.
+// It returns the cursor of the next page
+func (b *BrigadaSource) page(ctx context.Context, query string, cursor json.RawMessage, limit int) ([]Sentence, json.RawMessage, error) {
+	request := brigadaRequest{
+		Query:   query,
+		Limit:   limit,
+		Season:  b.season,
+		Episode: b.episode,
+		Cursor:  cursor,
+	}
+	if b.exactMatch {
+		request.ExactMatch = 1
+	}
+
+	jsonBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/api/search/anime/sentence", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("x-api-key", b.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := b.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(message)))
+	}
+
+	var parsedResponse BrigadaSOSResponse
+	err = json.NewDecoder(res.Body).Decode(&parsedResponse)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parsedResponse.Sentences, parsedResponse.Cursor, nil
+}
+
+func (b *BrigadaSource) note(sentence Sentence) models.Note {
+	starttime := strings.ReplaceAll(sentence.SegmentInfo.StartTime, ":", "_")
+	starttime = strings.ReplaceAll(starttime, ".", "_")
+	endtime := strings.ReplaceAll(sentence.SegmentInfo.EndTime, ":", "_")
+	endtime = strings.ReplaceAll(endtime, ".", "_")
+
+	name := strings.ReplaceAll(sentence.BasicInfo.NameAnimeEn, " ", "_")
+	name = strings.ReplaceAll(name, "/", "")
+	name = strings.ReplaceAll(name, ":", "_")
+	name = strings.ReplaceAll(name, "'", "")
+	name = strings.ReplaceAll(name, "!", "")
+	name = strings.ReplaceAll(name, "?", "")
+	name = strings.ReplaceAll(name, ".", "")
+	name = strings.ReplaceAll(name, ",", "")
+	name = strings.ReplaceAll(name, "(", "")
+	name = strings.ReplaceAll(name, ")", "")
+	name = strings.ReplaceAll(name, "\\", "")
+
+	return models.Note{
+		SentenceValue: sentence.SegmentInfo.ContentJp,
+		AudioValue:    sentence.MediaInfo.PathAudio,
+		ImageValue:    sentence.MediaInfo.PathImage,
+		Source:        b.DisplayName(),
+		MediaSource:   b,
+		Filename:      fmt.Sprintf("%s_%s_%s", name, starttime, endtime),
+		Metadata:      b.metadata(sentence),
+	}
+}
+
+func (b *BrigadaSource) metadata(sentence Sentence) models.NoteMetadata {
+	speakers := []string{}
+	for _, actor := range []string{sentence.SegmentInfo.ActorJa, sentence.SegmentInfo.ActorEn, sentence.SegmentInfo.ActorEs} {
+		if actor != "" && !slices.Contains(speakers, actor) {
+			speakers = append(speakers, actor)
+		}
+	}
+
+	// The times are h:mm:ss.mmm
+	return models.NoteMetadata{
+		Title:    sentence.BasicInfo.NameAnimeJp,
+		TitleEn:  sentence.BasicInfo.NameAnimeEn,
+		Speakers: speakers,
+		Start:    parseASSTime(sentence.SegmentInfo.StartTime),
+		End:      parseASSTime(sentence.SegmentInfo.EndTime),
+		NSFW:     sentence.SegmentInfo.IsNsfw,
+	}
+}
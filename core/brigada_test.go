@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBrigada is a BrigadaSOS API with sentences s0, s1... Cursors are
+// {"after": position}, the last page has a null cursor
+type fakeBrigada struct {
+	sentences int
+
+	mu       sync.Mutex
+	bodies   []string
+	requests []brigadaRequest
+}
+
+func (f *fakeBrigada) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/search/anime/sentence" || r.Header.Get("x-api-key") != "key" {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var request brigadaRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.bodies = append(f.bodies, string(body))
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+
+	var cursor struct {
+		After int `json:"after"`
+	}
+	if len(request.Cursor) > 0 {
+		json.Unmarshal(request.Cursor, &cursor)
+	}
+
+	start := min(cursor.After, f.sentences)
+	end := min(start+request.Limit, f.sentences)
+	response := BrigadaSOSResponse{Cursor: json.RawMessage("null")}
+	for i := start; i < end; i++ {
+		response.Sentences = append(response.Sentences, Sentence{SegmentInfo: SegmentInfo{ContentJp: fmt.Sprintf("s%d", i)}})
+	}
+	if end < f.sentences {
+		response.Cursor = json.RawMessage(fmt.Sprintf(`{"after":%d}`, end))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// sent returns the requests received until now, and their bodies
+func (f *fakeBrigada) sent() ([]brigadaRequest, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.requests), slices.Clone(f.bodies)
+}
+
+func newTestBrigada(t *testing.T, api http.Handler, options map[string]string) *BrigadaSource {
+	t.Helper()
+
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	source, err := NewBrigadaSource(SourceConfig{Name: "brigadasos", ApiKey: "key", BaseURL: server.URL, Limit: 3, Options: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func sentencesOf(t *testing.T, source *BrigadaSource, start, end int) []string {
+	t.Helper()
+
+	notes, err := source.FetchNotesFromQuery(context.Background(), "query", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sentences := []string{}
+	for i, note := range notes {
+		if note.NoteID != start+i {
+			t.Errorf("note %d has id %d", start+i, note.NoteID)
+		}
+		sentences = append(sentences, note.GetSentence())
+	}
+	return sentences
+}
+
+func TestBrigadaRequestBody(t *testing.T) {
+	api := &fakeBrigada{sentences: 1}
+	source := newTestBrigada(t, api, map[string]string{"exactMatch": "true", "season": "1, 2", "episode": "3"})
+
+	query := `say "hi" \ there`
+	_, err := source.FetchNotesFromQuery(context.Background(), query, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := api.sent()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	body, request := bodies[0], requests[0]
+
+	if !strings.Contains(body, `"query":"say \"hi\" \\ there"`) {
+		t.Errorf("query isn't escaped: %s", body)
+	}
+	if strings.Contains(body, `"cursor"`) {
+		t.Errorf("first page has a cursor: %s", body)
+	}
+	if request.Query != query {
+		t.Errorf("query = %q, want %q", request.Query, query)
+	}
+	if request.ExactMatch != 1 {
+		t.Errorf("exact_match = %d, want 1", request.ExactMatch)
+	}
+	if fmt.Sprint(request.Season) != "[1 2]" || fmt.Sprint(request.Episode) != "[3]" {
+		t.Errorf("season = %v, episode = %v, want [1 2] and [3]", request.Season, request.Episode)
+	}
+}
+
+func TestBrigadaPaging(t *testing.T) {
+	api := &fakeBrigada{sentences: 7}
+	source := newTestBrigada(t, api, nil)
+
+	// Pages of 3 sentences, the last one is cut
+	got := sentencesOf(t, source, 0, 4)
+	if fmt.Sprint(got) != "[s0 s1 s2 s3 s4]" {
+		t.Errorf("sentences 0-4 = %v", got)
+	}
+
+	// It continues from the cursor of s3, without paging from the start
+	api.mu.Lock()
+	api.requests = nil
+	api.mu.Unlock()
+	got = sentencesOf(t, source, 3, 5)
+	if fmt.Sprint(got) != "[s3 s4 s5]" {
+		t.Errorf("sentences 3-5 = %v", got)
+	}
+	if requests, _ := api.sent(); len(requests) == 0 || string(requests[0].Cursor) != `{"after":3}` {
+		t.Errorf("the saved cursor isn't used: %+v", requests)
+	}
+
+	// The last page has a null cursor, there is nothing after it
+	got = sentencesOf(t, source, 5, 9)
+	if fmt.Sprint(got) != "[s5 s6]" {
+		t.Errorf("sentences 5-9 = %v", got)
+	}
+	got = sentencesOf(t, source, 7, 9)
+	if len(got) != 0 {
+		t.Errorf("sentences 7-9 = %v, want none", got)
+	}
+}
+
+func TestBrigadaSeek(t *testing.T) {
+	api := &fakeBrigada{sentences: 7}
+	source := newTestBrigada(t, api, nil)
+
+	// A new source pages until start
+	got := sentencesOf(t, source, 4, 5)
+	if fmt.Sprint(got) != "[s4 s5]" {
+		t.Errorf("sentences 4-5 = %v", got)
+	}
+
+	// The seek stops at the null cursor of the last page
+	source = newTestBrigada(t, api, nil)
+	got = sentencesOf(t, source, 8, 10)
+	if len(got) != 0 {
+		t.Errorf("sentences 8-10 = %v, want none", got)
+	}
+}
+
+func TestBrigadaCursorsOfLastQuery(t *testing.T) {
+	source := newTestBrigada(t, &fakeBrigada{sentences: 7}, nil)
+
+	sentencesOf(t, source, 0, 4)
+	_, err := source.FetchNotesFromQuery(context.Background(), "other", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	for position := range source.cursors {
+		if position.query != "other" {
+			t.Errorf("the cursor of %+v is kept", position)
+		}
+	}
+}
+
+func TestBrigadaErrorStatus(t *testing.T) {
+	source := newTestBrigada(t, &fakeBrigada{}, nil)
+	source.apiKey = "wrong"
+
+	_, err := source.FetchNotesFromQuery(context.Background(), "query", 0, 10)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("error = %q, want the status and the body", err)
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MediaCache keeps remote media files on disk, keyed by their URL. When the
+// cache is bigger than maxSize, the least recently used files are removed
+type MediaCache struct {
+	dir     string
+	maxSize int64
+	client  *http.Client
+
+	mu sync.Mutex
+	// downloads in progress, so a URL is not downloaded twice at the same time
+	inflight map[string]chan struct{}
+}
+
+func NewMediaCache(dir string, maxSize int64) (*MediaCache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MediaCache{
+		dir:      dir,
+		maxSize:  maxSize,
+		client:   &http.Client{Timeout: time.Minute},
+		inflight: map[string]chan struct{}{},
+	}, nil
+}
+
+// DefaultCacheDir returns the directory used when the config doesn't set one
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, APPNAME)
+}
+
+// key returns the cache filename of a URL, the extension is kept so the
+// decoders can use it
+func (c *MediaCache) key(rawURL string) string {
+	sum := sha1.Sum([]byte(rawURL))
+	ext := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	return hex.EncodeToString(sum[:]) + ext
+}
+
+// Path returns the path of the cached file, downloading it if needed
+func (c *MediaCache) Path(rawURL string) (string, error) {
+	filename := filepath.Join(c.dir, c.key(rawURL))
+
+	for {
+		c.mu.Lock()
+		wait, downloading := c.inflight[rawURL]
+		if !downloading {
+			break
+		}
+		c.mu.Unlock()
+		<-wait
+	}
+
+	// Cache hit, update the access time used by the eviction
+	if _, err := os.Stat(filename); err == nil {
+		c.mu.Unlock()
+		now := time.Now()
+		os.Chtimes(filename, now, now)
+		return filename, nil
+	}
+
+	done := make(chan struct{})
+	c.inflight[rawURL] = done
+	c.mu.Unlock()
+
+	err := c.download(rawURL, filename)
+
+	c.mu.Lock()
+	delete(c.inflight, rawURL)
+	close(done)
+	c.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	c.evict()
+	return filename, nil
+}
+
+// Open opens the cached file, downloading it if needed
+func (c *MediaCache) Open(rawURL string) (*os.File, error) {
+	filename, err := c.Path(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filename)
+}
+
+// download saves the URL in a temporary file and then renames it,
+// so incomplete downloads are never used
+func (c *MediaCache) download(rawURL, filename string) error {
+	res, err := c.client.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: %s", rawURL, res.Status)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, res.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// evict removes the least recently used files until the cache fits in maxSize
+func (c *MediaCache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	var files []os.FileInfo
+	var size int64
+	for _, entry := range entries {
+		// Skip downloads in progress
+		if strings.HasPrefix(entry.Name(), "download-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, file := range files {
+		if size <= c.maxSize {
+			break
+		}
+		if os.Remove(filepath.Join(c.dir, file.Name())) == nil {
+			size -= file.Size()
+		}
+	}
+}
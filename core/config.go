@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +20,12 @@ const (
 type Config struct {
 	InfoChannel chan int `yaml:"-"`
 
+	// AnkiConnect connection
+	AnkiConnectUrl     string        `yaml:"ankiConnectUrl"`
+	AnkiConnectKey     string        `yaml:"ankiConnectKey"`
+	AnkiConnectVersion int           `yaml:"ankiConnectVersion"`
+	AnkiConnectTimeout time.Duration `yaml:"ankiConnectTimeout"`
+
 	MinningQuery string `yaml:"minningQuery"`
 	SearchQuery  string `yaml:"searchQuery"`
 
@@ -32,13 +39,114 @@ type Config struct {
 	MinningImageFieldName string `yaml:"minningImageFieldName"`
 	MinningAudioFieldName string `yaml:"minningAudioFieldName"`
 
+	// Query of the notes listed in the minning target picker
+	MinningTargetQuery string `yaml:"minningTargetQuery"`
+
 	PlayAudioAutomatically bool `yaml:"playAudioAutomatically"`
+
+	// Remote audio cache, the size is in megabytes. An empty dir
+	// uses the user cache dir
+	AudioCacheDir  string `yaml:"audioCacheDir"`
+	AudioCacheSize int64  `yaml:"audioCacheSize"`
+
+	// Memory limit of the decoded card images, in megabytes
+	ImageCacheSize int64 `yaml:"imageCacheSize"`
+
+	// How card images are drawn: auto (detects the terminal), halfblocks,
+	// kitty, sixel or iterm2
+	ImageProtocol string `yaml:"imageProtocol"`
+
+	// Notes created from a sentence. Empty field names are not filled,
+	// NewNoteTags are separated by spaces
+	NewNoteDeck             string `yaml:"newNoteDeck"`
+	NewNoteModel            string `yaml:"newNoteModel"`
+	NewNoteSentenceField    string `yaml:"newNoteSentenceField"`
+	NewNoteReadingField     string `yaml:"newNoteReadingField"`
+	NewNoteMorphsField      string `yaml:"newNoteMorphsField"`
+	NewNoteTranslationField string `yaml:"newNoteTranslationField"`
+	NewNoteImageField       string `yaml:"newNoteImageField"`
+	NewNoteAudioField       string `yaml:"newNoteAudioField"`
+	NewNoteTags             string `yaml:"newNoteTags"`
+	NewNoteSourceTags       bool   `yaml:"newNoteSourceTags"`
+
+	// External sources of sentences, see RegisterSource
+	ExternalSources []SourceConfig `yaml:"externalSources"`
+
+	// Filter of the notes of all the external sources
+	ExternalFilter ExternalFilter `yaml:"externalFilter"`
 }
 
-func LoadConfig() (*Config, error) {
+// DefaultConfig returns the config used when there is no config file,
+// it's also used to fill the missing keys of an existing one
+func DefaultConfig() *Config {
+	return &Config{
+		InfoChannel: make(chan int, 1),
+
+		AnkiConnectUrl:     "http://localhost:8765",
+		AnkiConnectKey:     "",
+		AnkiConnectVersion: 6,
+		AnkiConnectTimeout: 10 * time.Second,
+
+		MinningQuery:      "deck:morphman::86 tag:1T -tag:MT",
+		SearchQuery:       "deck:morphman tag:1T -tag:MT",
+		MorphFieldName:    "am-unknowns",
+		SentenceFieldName: "Expression",
+		ImageFieldName:    "Image,Picture",
+		AudioFieldName:    "Audio_Sentence",
+		KnownTag:          "am-known-manually",
+
+		MinningImageFieldName: "Picture",
+		MinningAudioFieldName: "SentenceAudio",
+		MinningTargetQuery:    "added:2",
+
+		PlayAudioAutomatically: false,
+
+		AudioCacheDir:  "",
+		AudioCacheSize: 200,
+
+		ImageCacheSize: 128,
+		ImageProtocol:  "auto",
+
+		NewNoteDeck:             "Mining",
+		NewNoteModel:            "Japanese sentences",
+		NewNoteSentenceField:    "Sentence",
+		NewNoteReadingField:     "Reading",
+		NewNoteMorphsField:      "Morphs",
+		NewNoteTranslationField: "Translation",
+		NewNoteImageField:       "Picture",
+		NewNoteAudioField:       "SentenceAudio",
+		NewNoteTags:             "anki-tui",
+		NewNoteSourceTags:       true,
+
+		ExternalSources: []SourceConfig{
+			{
+				Name:    "brigadasos",
+				Enabled: false,
+				ApiKey:  "",
+				BaseURL: BrigadaBaseURL,
+				Limit:   100,
+				Timeout: DefaultSourceTimeout,
+				Options: map[string]string{"exactMatch": "false", "season": "", "episode": ""},
+			},
+			{
+				Name:    "local",
+				Enabled: false,
+				Timeout: DefaultSourceTimeout,
+				Options: map[string]string{"path": "", "displayName": "Local"},
+			},
+			{
+				Name:    "sentences",
+				Enabled: false,
+				Timeout: DefaultSourceTimeout,
+				Options: map[string]string{"path": "", "columns": DefaultSentenceColumns, "displayName": "Tatoeba"},
+			},
+		},
+	}
+}
 
+// configDir returns the directory where the config file is stored
+func configDir() (string, error) {
 	var configPath string
-	var config *Config
 
 	// Get os name
 	switch runtime.GOOS {
@@ -51,51 +159,41 @@ func LoadConfig() (*Config, error) {
 			configPath = filepath.Join(os.Getenv("HOME") + "/.config")
 		}
 	default:
-		return nil, fmt.Errorf("unsupported os: %s", runtime.GOOS)
+		return "", fmt.Errorf("unsupported os: %s", runtime.GOOS)
 	}
 
+	return filepath.Join(configPath, APPNAME), nil
+}
+
+func LoadConfig() (*Config, error) {
+
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+
 	// If file exists load it
-	if _, err := os.Stat(filepath.Join(configPath, APPNAME, FILENAME)); err == nil {
+	if _, err := os.Stat(filepath.Join(dir, FILENAME)); err == nil {
 		// read file
-		data, err := os.ReadFile(filepath.Join(configPath, APPNAME, FILENAME))
+		data, err := os.ReadFile(filepath.Join(dir, FILENAME))
 		if err != nil {
 			return nil, err
 		}
 
-		err = yaml.Unmarshal(data, &config)
+		err = yaml.Unmarshal(data, config)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		// Create file
-		err := os.MkdirAll(filepath.Join(configPath, APPNAME), 0755)
-		if err != nil {
-			return nil, err
-		}
-
-		config = &Config{
-			InfoChannel: make(chan int, 1),
-
-			MinningQuery:      "deck:morphman::86 tag:1T -tag:MT",
-			SearchQuery:       "deck:morphman tag:1T -tag:MT",
-			MorphFieldName:    "am-unknowns",
-			SentenceFieldName: "Expression",
-			ImageFieldName:    "Image,Picture",
-			AudioFieldName:    "Audio_Sentence",
-			KnownTag:          "am-known-manually",
-
-			MinningImageFieldName: "Picture",
-			MinningAudioFieldName: "SentenceAudio",
-
-			PlayAudioAutomatically: false,
-		}
-
-		data, err := yaml.Marshal(config)
+		err := os.MkdirAll(dir, 0755)
 		if err != nil {
 			return nil, err
 		}
 
-		err = os.WriteFile(filepath.Join(configPath, APPNAME, FILENAME), data, 0755)
+		err = config.Save()
 		if err != nil {
 			return nil, err
 		}
@@ -105,20 +203,9 @@ func LoadConfig() (*Config, error) {
 }
 
 func (c *Config) Save() error {
-	var configPath string
-
-	// Get os name
-	switch runtime.GOOS {
-	case "windows":
-		configPath = os.Getenv("APPDATA")
-	case "linux":
-		if os.Getenv("XDG_CONFIG_HOME") != "" {
-			configPath = os.Getenv("XDG_CONFIG_HOME")
-		} else {
-			configPath = filepath.Join(os.Getenv("HOME") + "/.config")
-		}
-	default:
-		return fmt.Errorf("unsupported os: %s", runtime.GOOS)
+	dir, err := configDir()
+	if err != nil {
+		return err
 	}
 
 	data, err := yaml.Marshal(c)
@@ -126,7 +213,7 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	err = os.WriteFile(filepath.Join(configPath, APPNAME, FILENAME), data, 0755)
+	err = os.WriteFile(filepath.Join(dir, FILENAME), data, 0755)
 	if err != nil {
 		return err
 	}
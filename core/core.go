@@ -1,11 +1,10 @@
 package core
 
 import (
-	"time"
+	"context"
+	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/gopxl/beep"
-	"github.com/gopxl/beep/speaker"
 )
 
 var (
@@ -29,6 +28,8 @@ func Log(log InfoLog) tea.Cmd {
 type AnkiTui struct {
 	Config          *Config
 	AnkiConnect     *AnkiConnect
+	Audio           *Audio
+	Images          *Images
 	ExternalSources []ExternalSource
 	CollectionPath  string
 
@@ -38,26 +39,58 @@ type AnkiTui struct {
 	AvailableWidth  int
 }
 
-func NewAnkiTui() *AnkiTui {
+// NewAnkiTui loads the config and creates the app. It doesn't talk to
+// AnkiConnect, that's done by Connect once the UI is running.
+func NewAnkiTui() (*AnkiTui, error) {
 
-	sr := beep.SampleRate(48000)
-	speaker.Init(sr, sr.N(time.Second/2))
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
 
-	// TODO: Handle error
-	config, _ := LoadConfig()
+	cacheDir := config.AudioCacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(DefaultCacheDir(), "audio")
+	}
+	cache, err := NewMediaCache(cacheDir, config.AudioCacheSize*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	ankiconnect := NewAnkiConnect(
+		config.AnkiConnectUrl,
+		config.AnkiConnectKey,
+		config.AnkiConnectVersion,
+		config.AnkiConnectTimeout,
+	)
 
-	ankiconnect := NewAnkiConnect("http://localhost:8765", 6)
-	collectionPath, err := ankiconnect.GetMediaDirPath()
+	sources, err := NewExternalSources(config.ExternalSources)
 	if err != nil {
-		panic("Error getting the collection path")
+		return nil, err
 	}
 
 	return &AnkiTui{
-		Config:         config,
-		AnkiConnect:    NewAnkiConnect("http://localhost:8765", 6),
-		CollectionPath: collectionPath,
-		ExternalSources: []ExternalSource{
-			NewBrigadaSource("f34a3113-e164-4981-bd69-c58430fd64a1"),
-		},
+		Config:          config,
+		AnkiConnect:     ankiconnect,
+		Audio:           NewAudio(SpeakerSampleRate, cache),
+		Images:          NewImages(ImageWorkers, config.ImageCacheSize*1024*1024),
+		ExternalSources: sources,
+	}, nil
+}
+
+// Connect checks that AnkiConnect is reachable and loads the collection
+// media path
+func (a *AnkiTui) Connect(ctx context.Context) error {
+	_, err := a.AnkiConnect.Ping(ctx)
+	if err != nil {
+		return err
+	}
+
+	collectionPath, err := a.AnkiConnect.GetMediaDirPath()
+	if err != nil {
+		return err
 	}
+
+	a.CollectionPath = collectionPath
+	return nil
 }
@@ -0,0 +1,354 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corpusIndexVersion changes when the index format changes, old indexes
+// are rebuilt
+const corpusIndexVersion = 1
+
+var (
+	subtitleExts = map[string]bool{".srt": true, ".ass": true, ".ssa": true}
+	audioExts    = map[string]bool{".mp3": true, ".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".flac": true}
+	imageExts    = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+
+	// Media of the cue N of dir/ep01.srt: dir/ep01/N.ext, dir/ep01_N.ext
+	// or dir/ep01-N.ext, N can have leading zeros
+	mediaNameRegex = regexp.MustCompile(`^(.+?)[/_-]0*(\d+)$`)
+)
+
+// CorpusEntry is a sentence of a local corpus. Paths are relative to the
+// corpus root
+type CorpusEntry struct {
+	Text   string        `json:"text"`
+	Morphs []string      `json:"morphs"`
+	Title  string        `json:"title"`
+	File   string        `json:"file"`
+	Index  int           `json:"index"`
+	Start  time.Duration `json:"start,omitempty"`
+	End    time.Duration `json:"end,omitempty"`
+	Audio  string        `json:"audio,omitempty"`
+	Image  string        `json:"image,omitempty"`
+
+	Translation string `json:"translation,omitempty"`
+}
+
+// corpusIndex is the file saved in the index directory
+type corpusIndex struct {
+	Version int    `json:"version"`
+	Root    string `json:"root"`
+
+	// Modification time of every indexed file, they are indexed again
+	// when it changes
+	Files   map[string]time.Time `json:"files"`
+	Entries []CorpusEntry        `json:"entries"`
+}
+
+// Corpus is an index of sentences (subtitles or sentence files) with their
+// media, to search them by text and morphs
+type Corpus struct {
+	Root    string
+	entries []CorpusEntry
+
+	// morphs are the entries of every morph
+	morphs map[string][]int
+}
+
+// corpusFile is an indexed file of the corpus
+type corpusFile struct {
+	path    string
+	modTime time.Time
+}
+
+// corpusMedia is the audio and image of a cue
+type corpusMedia struct {
+	audio string
+	image string
+}
+
+// OpenCorpus loads the index of root from indexDir. Only the subtitle
+// files that changed since the last time are indexed again
+func OpenCorpus(root, indexDir string) (*Corpus, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files, media, err := scanCorpus(root)
+	if err != nil {
+		return nil, err
+	}
+
+	corpus, err := openCorpus(root, root, files, indexDir, func(rel string) ([]CorpusEntry, error) {
+		return indexSubtitles(root, rel, media)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Media can be added or removed without changing the subtitles, the
+	// cached entries get it from this scan too
+	for i := range corpus.entries {
+		entry := &corpus.entries[i]
+		m := cueMedia(media, entry.File, entry.Index)
+		entry.Audio, entry.Image = m.audio, m.image
+	}
+	return corpus, nil
+}
+
+// openCorpus loads the index of the files from indexDir, the files that
+// changed are indexed again with index. key identifies the index
+func openCorpus(key, root string, files []corpusFile, indexDir string, index func(rel string) ([]CorpusEntry, error)) (*Corpus, error) {
+	sum := sha1.Sum([]byte(key))
+	indexPath := filepath.Join(indexDir, hex.EncodeToString(sum[:])+".json")
+
+	// An unreadable index is rebuilt
+	old := loadCorpusIndex(indexPath)
+
+	current := corpusIndex{Version: corpusIndexVersion, Root: root, Files: map[string]time.Time{}}
+	changed := len(old.Files) != len(files)
+	oldEntries := map[string][]CorpusEntry{}
+	for _, entry := range old.Entries {
+		oldEntries[entry.File] = append(oldEntries[entry.File], entry)
+	}
+
+	for _, file := range files {
+		current.Files[file.path] = file.modTime
+
+		if modTime, ok := old.Files[file.path]; ok && modTime.Equal(file.modTime) {
+			current.Entries = append(current.Entries, oldEntries[file.path]...)
+			continue
+		}
+
+		changed = true
+		entries, err := index(file.path)
+		if err != nil {
+			return nil, err
+		}
+		current.Entries = append(current.Entries, entries...)
+	}
+
+	if changed {
+		err := saveCorpusIndex(indexPath, current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newCorpus(root, current.Entries), nil
+}
+
+func newCorpus(root string, entries []CorpusEntry) *Corpus {
+	c := &Corpus{Root: root, entries: entries, morphs: map[string][]int{}}
+	for i, entry := range entries {
+		for _, morph := range entry.Morphs {
+			c.morphs[morph] = append(c.morphs[morph], i)
+		}
+	}
+	return c
+}
+
+// Len returns the number of sentences of the corpus
+func (c *Corpus) Len() int {
+	return len(c.entries)
+}
+
+// Search returns the sentences that have a morph or contain one of the
+// words of query (separated by spaces). Sentences with more words come
+// first, the others keep the corpus order
+func (c *Corpus) Search(query string, offset, limit int) []CorpusEntry {
+	scores := map[int]int{}
+	for _, word := range strings.Fields(query) {
+		matched := map[int]bool{}
+		for _, i := range c.morphs[word] {
+			matched[i] = true
+		}
+		for i, entry := range c.entries {
+			if !matched[i] && strings.Contains(entry.Text, word) {
+				matched[i] = true
+			}
+		}
+		for i := range matched {
+			scores[i]++
+		}
+	}
+
+	found := make([]int, 0, len(scores))
+	for i := range scores {
+		found = append(found, i)
+	}
+	sort.Slice(found, func(a, b int) bool {
+		if scores[found[a]] != scores[found[b]] {
+			return scores[found[a]] > scores[found[b]]
+		}
+		return found[a] < found[b]
+	})
+
+	if offset >= len(found) {
+		return []CorpusEntry{}
+	}
+	found = found[offset:]
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+
+	entries := make([]CorpusEntry, len(found))
+	for i, index := range found {
+		entries[i] = c.entries[index]
+	}
+	return entries
+}
+
+// scanCorpus returns the subtitle files of root, and the media of every
+// cue keyed by mediaKey
+func scanCorpus(root string) ([]corpusFile, map[string]corpusMedia, error) {
+	files := []corpusFile{}
+	media := map[string]corpusMedia{}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		ext := strings.ToLower(filepath.Ext(rel))
+
+		switch {
+		case subtitleExts[ext]:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, corpusFile{path: rel, modTime: info.ModTime()})
+
+		case audioExts[ext], imageExts[ext]:
+			match := mediaNameRegex.FindStringSubmatch(strings.TrimSuffix(rel, filepath.Ext(rel)))
+			if match == nil {
+				return nil
+			}
+			index, _ := strconv.Atoi(match[2])
+			key := mediaKey(match[1], index)
+
+			m := media[key]
+			if audioExts[ext] {
+				m.audio = rel
+			} else {
+				m.image = rel
+			}
+			media[key] = m
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, media, nil
+}
+
+func mediaKey(base string, index int) string {
+	return fmt.Sprintf("%s#%d", base, index)
+}
+
+// cueMedia returns the media of the cue index of the subtitle file rel
+func cueMedia(media map[string]corpusMedia, rel string, index int) corpusMedia {
+	return media[mediaKey(strings.TrimSuffix(rel, filepath.Ext(rel)), index)]
+}
+
+// indexSubtitles parses a subtitle file and tokenizes its cues
+func indexSubtitles(root, rel string, media map[string]corpusMedia) ([]CorpusEntry, error) {
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cues, err := ParseSubtitles(file, rel)
+	if err != nil {
+		return nil, fmt.Errorf("indexing %s: %w", rel, err)
+	}
+
+	base := strings.TrimSuffix(rel, filepath.Ext(rel))
+
+	// The title is the folder of the subtitles, or the file name if
+	// it's in the root
+	title := filepath.Base(filepath.Dir(filepath.FromSlash(rel)))
+	if title == "." {
+		title = filepath.Base(base)
+	}
+
+	entries := make([]CorpusEntry, len(cues))
+	for i, cue := range cues {
+		m := cueMedia(media, rel, cue.Index)
+		entries[i] = CorpusEntry{
+			Text:   cue.Text,
+			Morphs: ParseJpMorphs(cue.Text),
+			Title:  title,
+			File:   rel,
+			Index:  cue.Index,
+			Start:  cue.Start,
+			End:    cue.End,
+			Audio:  m.audio,
+			Image:  m.image,
+		}
+	}
+	return entries, nil
+}
+
+func loadCorpusIndex(path string) corpusIndex {
+	index := corpusIndex{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return index
+	}
+	if json.Unmarshal(data, &index) != nil || index.Version != corpusIndexVersion {
+		return corpusIndex{}
+	}
+	return index
+}
+
+// saveCorpusIndex writes the index to a temporary file first, so an
+// interrupted save doesn't leave a broken index
+func saveCorpusIndex(path string, index corpusIndex) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// errEmptyCorpus is returned when the corpus has no sentences
+var errEmptyCorpus = errors.New("no sentences found")
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// QueryCursor pages through the notes of a query. The note IDs are requested
+// once (findNotes) and cached, so every page only needs a notesInfo request.
+// It's safe to use from multiple goroutines
+type QueryCursor struct {
+	mu     sync.Mutex
+	client *AnkiConnect
+
+	query  string
+	ids    []int
+	loaded bool
+	offset int
+}
+
+func (c *AnkiConnect) NewQueryCursor(query string) *QueryCursor {
+	return &QueryCursor{
+		client: c,
+		query:  query,
+	}
+}
+
+func (q *QueryCursor) Query() string {
+	return q.query
+}
+
+// Next returns the next n notes of the query
+func (q *QueryCursor) Next(n int) ([]models.Note, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.loaded {
+		res, err := q.client.FindNotesIDByQuery(q.query)
+		if err != nil {
+			return nil, err
+		}
+		q.ids = res.Result
+		q.loaded = true
+		q.offset = 0
+	}
+
+	start := q.offset
+	end := start + n
+	if end > len(q.ids) {
+		end = len(q.ids)
+	}
+
+	if start >= end {
+		return []models.Note{}, nil
+	}
+
+	notesInfo, err := q.client.FetchNotesFromID(q.ids[start:end])
+	if err != nil {
+		return nil, err
+	}
+
+	q.offset = end
+	return notesInfo.Result, nil
+}
+
+// Total returns the number of notes of the query, it's 0 until
+// the first page is requested
+func (q *QueryCursor) Total() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ids)
+}
+
+// Done reports whether every note of the query was already returned
+func (q *QueryCursor) Done() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.loaded && q.offset >= len(q.ids)
+}
+
+// Remove drops deleted notes from the cached IDs without re-querying
+func (q *QueryCursor) Remove(noteIDs ...int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	removed := make(map[int]bool, len(noteIDs))
+	for _, id := range noteIDs {
+		removed[id] = true
+	}
+
+	ids := q.ids[:0]
+	for i, id := range q.ids {
+		if !removed[id] {
+			ids = append(ids, id)
+		} else if i < q.offset {
+			q.offset--
+		}
+	}
+	q.ids = ids
+}
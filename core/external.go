@@ -1,128 +1,209 @@
 package core
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xyaman/anki-tui/models"
 )
 
+// ExternalSource is a source of sentences outside of the collection. The
+// notes it returns have it as MediaSource, so their media is opened and
+// downloaded by the source
 type ExternalSource interface {
-	FetchNotesFromQuery(query string, start, end int) ([]models.Note, error)
+	models.MediaSource
+
+	// Name is the name the source is registered with
+	Name() string
+	// DisplayName is shown in the UI and used as the note source
+	DisplayName() string
+	Capabilities() Capabilities
+
+	// FetchNotesFromQuery returns the notes from start to end of the query,
+	// it stops when ctx is done
+	FetchNotesFromQuery(ctx context.Context, query string, start, end int) ([]models.Note, error)
 }
 
-type BrigadaSource struct {
-	http   http.Client
-	apiKey string
+// Capabilities are the features supported by an external source
+type Capabilities uint
+
+const (
+	// HasImages means the notes can have an image
+	HasImages Capabilities = 1 << iota
+	// HasAudio means the notes can have audio
+	HasAudio
+	// CanDownload means the media can be stored in the collection
+	CanDownload
+)
+
+// Has reports whether all the capabilities c are supported
+func (caps Capabilities) Has(c Capabilities) bool {
+	return caps&c == c
 }
 
-type BrigadaSOSResponse struct {
-	Sentences []Sentence `json:"sentences"`
+// NoteCapabilities returns the capabilities of the source of an external
+// note, without the media the note doesn't have. Notes of the collection
+// have none
+func NoteCapabilities(note *models.Note) Capabilities {
+	source, ok := note.MediaSource.(ExternalSource)
+	if !ok {
+		return 0
+	}
+
+	caps := source.Capabilities()
+	if note.ImageRef() == "" {
+		caps &^= HasImages
+	}
+	if note.AudioRef() == "" {
+		caps &^= HasAudio
+	}
+	return caps
 }
 
-type Sentence struct {
-	BasicInfo   BasicInfo   `json:"basic_info"`
-	SegmentInfo SegmentInfo `json:"segment_info"`
-	MediaInfo   MediaInfo   `json:"media_info"`
+// urlMedia implements models.MediaSource for sources whose image and audio
+// values are URLs. Anki downloads the files itself when they are mined
+type urlMedia struct {
+	// Extensions used when the URL path has none
+	imageExt string
+	audioExt string
 }
 
-type BasicInfo struct {
-	NameAnimeJp string `json:"name_anime_jp"`
-	NameAnimeEn string `json:"name_anime_en"`
+func (u urlMedia) OpenImage(note *models.Note) (io.ReadCloser, error) {
+	return models.OpenURL(note.ImageRef())
 }
 
-type SegmentInfo struct {
-	ContentJp string `json:"content_jp"`
-	IsNsfw    bool   `json:"is_nsfw"`
-	ActorJa   string `json:"actor_ja"`
-	ActorEn   string `json:"actor_en"`
-	ActorEs   string `json:"actor_es"`
-	StartTime string `json:"start_time"`
-	EndTime   string `json:"end_time"`
+func (u urlMedia) OpenAudio(note *models.Note) (io.ReadCloser, error) {
+	return models.OpenURL(note.AudioRef())
 }
 
-type MediaInfo struct {
-	PathImage string `json:"path_image"`
-	PathAudio string `json:"path_audio"`
-	PathVideo string `json:"path_video"`
+func (u urlMedia) DownloadImage(note *models.Note, store models.MediaStore) (string, error) {
+	return store.StoreMediaFileFromURL(note.GetFilename()+urlExt(note.ImageRef(), u.imageExt), note.ImageRef())
 }
 
-func NewBrigadaSource(apiKey string) *BrigadaSource {
-	return &BrigadaSource{
-		http:   http.Client{},
-		apiKey: apiKey,
+func (u urlMedia) DownloadAudio(note *models.Note, store models.MediaStore) (string, error) {
+	return store.StoreMediaFileFromURL(note.GetFilename()+urlExt(note.AudioRef(), u.audioExt), note.AudioRef())
+}
+
+// urlExt returns the extension of the URL path, or fallback if it has none
+func urlExt(rawURL, fallback string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || path.Ext(u.Path) == "" {
+		return fallback
 	}
+	return path.Ext(u.Path)
 }
 
-func (b *BrigadaSource) FetchNotesFromQuery(query string, start, end int) ([]models.Note, error) {
+// SourceConfig is an external source entry of config.yaml. Options has
+// the settings that are specific to a source. HideNSFW hides the NSFW
+// notes of the source, also when the external filter shows them
+type SourceConfig struct {
+	Name     string            `yaml:"name"`
+	Enabled  bool              `yaml:"enabled"`
+	ApiKey   string            `yaml:"apiKey"`
+	BaseURL  string            `yaml:"baseUrl"`
+	Limit    int               `yaml:"limit,omitempty"`
+	HideNSFW bool              `yaml:"hideNsfw"`
+	Timeout  time.Duration     `yaml:"timeout"`
+	Options  map[string]string `yaml:"options,omitempty"`
+}
 
-	jsonBody := []byte(fmt.Sprintf(`{"query":"%s","exact_match":0,"limit":%d,"content_sort":null,"random_seed":null,"season":null,"episode":null}`, query, end-start+1))
+// SourceFactory creates an external source from its config
+type SourceFactory func(config SourceConfig) (ExternalSource, error)
 
-	req, err := http.NewRequest("POST", "https://api.brigadasos.xyz/api/v1/api/search/anime/sentence", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("x-api-key", b.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]SourceFactory{}
+)
+
+// RegisterSource makes an external source available by name, so it can be
+// enabled in config.yaml. Sources register themselves in init, registering
+// the same name twice panics
+func RegisterSource(name string, factory SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
 
-	res, err := b.http.Do(req)
-	if err != nil {
-		return nil, err
+	if factory == nil {
+		panic("core: RegisterSource factory is nil")
 	}
+	if _, dup := sources[name]; dup {
+		panic("core: RegisterSource called twice for " + name)
+	}
+	sources[name] = factory
+}
 
-	defer res.Body.Close()
-	var parsedResponse BrigadaSOSResponse
-	err = json.NewDecoder(res.Body).Decode(&parsedResponse)
-	if err != nil {
-		return nil, err
+// SourceNames returns the names of the registered sources, sorted
+func SourceNames() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return sourceNames()
+}
+
+// sourceNames is SourceNames without locking sourcesMu
+func sourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// NewExternalSources creates the enabled sources of the config, in order
+func NewExternalSources(configs []SourceConfig) ([]ExternalSource, error) {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
 
-	notes := make([]models.Note, len(parsedResponse.Sentences))
-	for i, sentence := range parsedResponse.Sentences {
-
-		starttime := strings.ReplaceAll(sentence.SegmentInfo.StartTime, ":", "_")
-		starttime = strings.ReplaceAll(starttime, ".", "_")
-		endtime := strings.ReplaceAll(sentence.SegmentInfo.EndTime, ":", "_")
-		endtime = strings.ReplaceAll(endtime, ".", "_")
-
-		name := strings.ReplaceAll(sentence.BasicInfo.NameAnimeEn, " ", "_")
-		name = strings.ReplaceAll(name, "/", "")
-		name = strings.ReplaceAll(name, ":", "_")
-		name = strings.ReplaceAll(name, "'", "")
-		name = strings.ReplaceAll(name, "!", "")
-		name = strings.ReplaceAll(name, "?", "")
-		name = strings.ReplaceAll(name, ".", "")
-		name = strings.ReplaceAll(name, ",", "")
-		name = strings.ReplaceAll(name, "(", "")
-		name = strings.ReplaceAll(name, ")", "")
-		name = strings.ReplaceAll(name, "\\", "")
-
-		notes[i] = models.Note{
-			NoteID:        i,
-			SentenceValue: sentence.SegmentInfo.ContentJp,
-			AudioValue:    sentence.MediaInfo.PathAudio,
-			ImageValue:    sentence.MediaInfo.PathImage,
-			Tags:          []string{sentence.BasicInfo.NameAnimeJp},
-			Source:        "BrigadaSOS",
-			Filename:      fmt.Sprintf("%s_%s_%s", name, starttime, endtime),
+	var created []ExternalSource
+	for _, config := range configs {
+		if !config.Enabled {
+			continue
+		}
+
+		factory, ok := sources[config.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown external source %q (available: %s)", config.Name, strings.Join(sourceNames(), ", "))
+		}
+
+		source, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("external source %s: %w", config.Name, err)
 		}
-	}
 
-	// Fetch images concurrently and add them to the notes
-	// wait until is complete to return the function
-	wg := sync.WaitGroup{}
-	for i := range notes {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			notes[i].GetImage("")
-		}(i)
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = DefaultSourceTimeout
+		}
+		created = append(created, configuredSource{ExternalSource: source, timeout: timeout, hideNSFW: config.HideNSFW})
 	}
-	wg.Wait()
+	return created, nil
+}
+
+// DefaultSourceTimeout is the search timeout of the sources that don't set one
+const DefaultSourceTimeout = 10 * time.Second
+
+// configuredSource applies the settings of SourceConfig that are common to
+// all the sources. It limits the time a source can take to search
+type configuredSource struct {
+	ExternalSource
+	timeout  time.Duration
+	hideNSFW bool
+}
+
+func (c configuredSource) FetchNotesFromQuery(ctx context.Context, query string, start, end int) ([]models.Note, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.ExternalSource.FetchNotesFromQuery(ctx, query, start, end)
+}
 
-	return notes, nil
+// HidesNSFW reports whether the NSFW notes of the source are hidden. They
+// are removed by ExternalSearch, so the source positions don't change
+func (c configuredSource) HidesNSFW() bool {
+	return c.hideNSFW
 }
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// ExternalFilter chooses the notes of the external sources that are shown.
+// Titles and speakers match when they contain one of the values, ignoring
+// case. Empty lists don't filter
+type ExternalFilter struct {
+	HideNSFW      bool     `yaml:"hideNsfw"`
+	IncludeTitles []string `yaml:"includeTitles"`
+	ExcludeTitles []string `yaml:"excludeTitles"`
+	Speakers      []string `yaml:"speakers"`
+}
+
+// Match reports whether the note passes the filter. Notes without a title
+// or speaker don't pass IncludeTitles or Speakers
+func (f ExternalFilter) Match(note *models.Note) bool {
+	metadata := note.Metadata
+
+	if f.HideNSFW && metadata.NSFW {
+		return false
+	}
+
+	titles := []string{metadata.Title, metadata.TitleEn}
+	if len(f.IncludeTitles) > 0 && !containsAny(titles, f.IncludeTitles) {
+		return false
+	}
+	if containsAny(titles, f.ExcludeTitles) {
+		return false
+	}
+
+	if len(f.Speakers) > 0 && !containsAny(metadata.Speakers, f.Speakers) {
+		return false
+	}
+
+	return true
+}
+
+// containsAny reports whether a value contains one of the patterns
+func containsAny(values, patterns []string) bool {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		value = strings.ToLower(value)
+
+		for _, pattern := range patterns {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if pattern != "" && strings.Contains(value, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
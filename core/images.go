@@ -0,0 +1,105 @@
+package core
+
+import (
+	"image"
+	"path/filepath"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+const (
+	// ImageWorkers is the number of images that are opened and decoded at
+	// the same time, the rest wait for a free worker
+	ImageWorkers = 4
+
+	// renderedCacheSize is the memory limit of the rendered images
+	renderedCacheSize = 16 * 1024 * 1024
+)
+
+// Images decodes the images of the notes in the background. The number of
+// images loaded at the same time is limited, so opening many external
+// notes doesn't flood their source.
+//
+// Decoded images are cached by their path, and rendered images by their
+// path and width, so showing an image again is instant
+type Images struct {
+	workers chan struct{}
+
+	decoded  *lru[string, image.Image]
+	rendered *lru[renderedKey, string]
+}
+
+type renderedKey struct {
+	path  string
+	width int
+}
+
+// NewImages creates the loader, cacheSize is the memory limit of the
+// decoded images in bytes
+func NewImages(workers int, cacheSize int64) *Images {
+	return &Images{
+		workers:  make(chan struct{}, max(workers, 1)),
+		decoded:  newLRU[string, image.Image](cacheSize),
+		rendered: newLRU[renderedKey, string](renderedCacheSize),
+	}
+}
+
+// Path returns the cache key of the selected image of the note, the file
+// in the collection for Anki notes
+func (i *Images) Path(note *models.Note, collectionPath string) string {
+	ref := note.ImageRef()
+	if ref == "" || note.IsExternal() {
+		return ref
+	}
+	return filepath.Join(collectionPath, filepath.Base(ref))
+}
+
+// Cached returns the selected image of the note if it's already decoded
+func (i *Images) Cached(note *models.Note, collectionPath string) (image.Image, bool) {
+	return i.decoded.Get(i.Path(note, collectionPath))
+}
+
+// Load opens and decodes the selected image of the note, waiting for a
+// free worker. It returns nil if the note has no image. The note isn't
+// modified, so it can be a copy
+func (i *Images) Load(note models.Note, collectionPath string) (image.Image, error) {
+	path := i.Path(&note, collectionPath)
+	if img, ok := i.decoded.Get(path); ok {
+		return img, nil
+	}
+
+	i.workers <- struct{}{}
+	defer func() { <-i.workers }()
+
+	img, err := note.GetImage(collectionPath)
+	if err != nil || img == nil {
+		return img, err
+	}
+
+	i.decoded.Add(path, img, imageSize(img))
+	return img, nil
+}
+
+// Render returns the image at path rendered with width columns, render
+// is only called when it isn't cached. An empty path isn't cached
+func (i *Images) Render(path string, width int, img image.Image, render func(width int, img image.Image) string) string {
+	if path == "" {
+		return render(width, img)
+	}
+
+	key := renderedKey{path: path, width: width}
+	if s, ok := i.rendered.Get(key); ok {
+		return s
+	}
+
+	s := render(width, img)
+	i.rendered.Add(key, s, int64(len(s)))
+	return s
+}
+
+// imageSize estimates the memory used by a decoded image, as 4 bytes
+// per pixel
+func imageSize(img image.Image) int64 {
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+func init() {
+	RegisterSource("local", func(config SourceConfig) (ExternalSource, error) {
+		if config.Options["path"] == "" {
+			return nil, errors.New("options.path is empty")
+		}
+		return NewLocalSource(config), nil
+	})
+
+	RegisterSource("sentences", func(config SourceConfig) (ExternalSource, error) {
+		if config.Options["path"] == "" {
+			return nil, errors.New("options.path is empty")
+		}
+		return NewSentenceSource(config), nil
+	})
+}
+
+// fileMedia implements models.MediaSource for sources whose image and audio
+// values are paths of local files
+type fileMedia struct{}
+
+func (fileMedia) OpenImage(note *models.Note) (io.ReadCloser, error) {
+	return os.Open(note.ImageRef())
+}
+
+func (fileMedia) OpenAudio(note *models.Note) (io.ReadCloser, error) {
+	return os.Open(note.AudioRef())
+}
+
+func (fileMedia) DownloadImage(note *models.Note, store models.MediaStore) (string, error) {
+	return storeFile(note.ImageRef(), note.GetFilename(), store)
+}
+
+func (fileMedia) DownloadAudio(note *models.Note, store models.MediaStore) (string, error) {
+	return storeFile(note.AudioRef(), note.GetFilename(), store)
+}
+
+// storeFile saves the file in the collection as name, keeping its extension
+func storeFile(path, name string, store models.MediaStore) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return store.StoreMediaFile(name+filepath.Ext(path), data)
+}
+
+// LocalSource searches a local corpus, so it works offline. It's a directory
+// of subtitles (NewLocalSource) or a file of sentences (NewSentenceSource),
+// set in options.path
+type LocalSource struct {
+	fileMedia
+
+	name        string
+	displayName string
+	root        string
+	caps        Capabilities
+
+	// The corpus is indexed on the first search
+	open   func() (*Corpus, error)
+	once   sync.Once
+	corpus *Corpus
+	err    error
+}
+
+// NewLocalSource creates a source of the subtitles of a directory, the
+// media of every subtitle line is found by name (see OpenCorpus)
+func NewLocalSource(config SourceConfig) *LocalSource {
+	s := newLocalSource(config, "Local")
+	s.caps = HasImages | HasAudio | CanDownload
+	s.open = func() (*Corpus, error) {
+		return OpenCorpus(s.root, filepath.Join(DefaultCacheDir(), "corpus"))
+	}
+	return s
+}
+
+// NewSentenceSource creates a source of a TSV or JSONL file of sentences
+// and translations, like a Tatoeba export (see OpenSentenceCorpus). The
+// TSV columns are set in options.columns
+func NewSentenceSource(config SourceConfig) *LocalSource {
+	s := newLocalSource(config, "Sentences")
+	s.caps = sentenceCapabilities(config.Options["path"], config.Options["columns"])
+	s.open = func() (*Corpus, error) {
+		return OpenSentenceCorpus(s.root, config.Options["columns"], filepath.Join(DefaultCacheDir(), "corpus"))
+	}
+	return s
+}
+
+// sentenceCapabilities returns the media a sentence file can have. JSONL
+// lines can set any of them, TSV files only the media of their columns
+func sentenceCapabilities(path, columns string) Capabilities {
+	if strings.EqualFold(filepath.Ext(path), ".jsonl") {
+		return HasImages | HasAudio | CanDownload
+	}
+	if columns == "" {
+		columns = DefaultSentenceColumns
+	}
+
+	caps := CanDownload
+	for _, column := range strings.Split(columns, ",") {
+		switch strings.TrimSpace(column) {
+		case "image":
+			caps |= HasImages
+		case "audio":
+			caps |= HasAudio
+		}
+	}
+	return caps
+}
+
+func newLocalSource(config SourceConfig, displayName string) *LocalSource {
+	if config.Options["displayName"] != "" {
+		displayName = config.Options["displayName"]
+	}
+
+	return &LocalSource{
+		name:        config.Name,
+		displayName: displayName,
+		root:        config.Options["path"],
+	}
+}
+
+func (s *LocalSource) Name() string {
+	return s.name
+}
+
+func (s *LocalSource) DisplayName() string {
+	return s.displayName
+}
+
+func (s *LocalSource) Capabilities() Capabilities {
+	return s.caps
+}
+
+// Corpus returns the index of the corpus, it's built the first time
+func (s *LocalSource) Corpus() (*Corpus, error) {
+	s.once.Do(func() {
+		s.corpus, s.err = s.open()
+		if s.err == nil && s.corpus.Len() == 0 {
+			s.err = fmt.Errorf("%s: %w", s.root, errEmptyCorpus)
+		}
+	})
+	return s.corpus, s.err
+}
+
+func (s *LocalSource) FetchNotesFromQuery(ctx context.Context, query string, start, end int) ([]models.Note, error) {
+	// The first search indexes the corpus, it continues in the background
+	// if ctx is done before
+	indexed := make(chan struct{})
+	go func() {
+		s.Corpus()
+		close(indexed)
+	}()
+
+	select {
+	case <-indexed:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s is still being indexed: %w", s.root, ctx.Err())
+	}
+
+	corpus, err := s.Corpus()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := corpus.Search(query, start, end-start+1)
+	notes := make([]models.Note, len(entries))
+	for i, entry := range entries {
+		notes[i] = s.note(corpus, entry)
+		notes[i].NoteID = start + i
+	}
+	return notes, nil
+}
+
+func (s *LocalSource) note(corpus *Corpus, entry CorpusEntry) models.Note {
+	path := func(rel string) string {
+		if rel == "" {
+			return ""
+		}
+		// Sentence files can have absolute media paths
+		rel = filepath.FromSlash(rel)
+		if filepath.IsAbs(rel) {
+			return rel
+		}
+		return filepath.Join(corpus.Root, rel)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(entry.File), filepath.Ext(entry.File))
+	return models.Note{
+		SentenceValue:    entry.Text,
+		TranslationValue: entry.Translation,
+		AudioValue:       path(entry.Audio),
+		ImageValue:       path(entry.Image),
+		Source:           s.displayName,
+		MediaSource:      s,
+		Filename:         sanitizeFilename(fmt.Sprintf("%s_%s_%d", entry.Title, base, entry.Index)),
+		Metadata: models.NoteMetadata{
+			Title: entry.Title,
+			Start: entry.Start,
+			End:   entry.End,
+		},
+	}
+}
+
+var unsafeFilenameRegex = regexp.MustCompile(`[^\p{L}\p{N}_-]+`)
+
+// sanitizeFilename replaces the characters that are not safe in a media
+// filename with _
+func sanitizeFilename(name string) string {
+	return strings.Trim(unsafeFilenameRegex.ReplaceAllString(name, "_"), "_")
+}
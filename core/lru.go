@@ -0,0 +1,69 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru keeps values in memory until their total size is over maxSize, then
+// the least recently used ones are removed. It's safe to use from multiple
+// goroutines
+type lru[K comparable, V any] struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	items   map[K]*list.Element
+
+	// Most recently used first
+	order *list.List
+}
+
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+func newLRU[K comparable, V any](maxSize int64) *lru[K, V] {
+	return &lru[K, V]{maxSize: maxSize, items: map[K]*list.Element{}, order: list.New()}
+}
+
+func (c *lru[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruItem[K, V]).value, true
+}
+
+// Add stores the value, replacing the previous one of key. Values bigger
+// than maxSize are not stored
+func (c *lru[K, V]) Add(key K, value V, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+	if size > c.maxSize {
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem[K, V]{key: key, value: value, size: size})
+	c.size += size
+
+	for c.size > c.maxSize {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lru[K, V]) remove(element *list.Element) {
+	item := c.order.Remove(element).(*lruItem[K, V])
+	delete(c.items, item.key)
+	c.size -= item.size
+}
@@ -3,16 +3,32 @@ package core
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/ikawaha/kagome-dict/ipa"
 	"github.com/ikawaha/kagome/v2/tokenizer"
 )
 
+var (
+	taggerOnce sync.Once
+	tagger     *tokenizer.Tokenizer
+)
+
+// jpTokenizer returns the tokenizer, the dictionary is loaded only once
+func jpTokenizer() *tokenizer.Tokenizer {
+	taggerOnce.Do(func() {
+		var err error
+		tagger, err = tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
+		if err != nil {
+			panic(err)
+		}
+	})
+	return tagger
+}
+
 func ParseJpSentence(input string) string {
-	tagger, err := tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
-	if err != nil {
-		panic(err)
-	}
+	tagger := jpTokenizer()
 
 	// change all spaces to full-width spaces of input
 	// remove all spaces
@@ -34,3 +50,37 @@ func ParseJpSentence(input string) string {
 
 	return rawSentence
 }
+
+// ignoredPOS are the parts of speech that are not morphs
+var ignoredPOS = map[string]bool{
+	"助詞":  true,
+	"助動詞": true,
+	"記号":  true,
+}
+
+// ParseJpMorphs returns the dictionary form of the words of the sentence,
+// without particles, auxiliary verbs and symbols. Words are not repeated
+func ParseJpMorphs(input string) []string {
+	morphs := []string{}
+	seen := map[string]bool{}
+	for _, token := range jpTokenizer().Tokenize(input) {
+		pos := token.POS()
+		if len(pos) > 0 && ignoredPOS[pos[0]] {
+			continue
+		}
+
+		morph, ok := token.BaseForm()
+		if !ok || morph == "*" {
+			morph = token.Surface
+		}
+		// Skip punctuation the dictionary doesn't know
+		isWord := strings.ContainsFunc(morph, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsNumber(r)
+		})
+		if isWord && !seen[morph] {
+			seen[morph] = true
+			morphs = append(morphs, morph)
+		}
+	}
+	return morphs
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// ExternalSearch pages through the notes of a query in the external sources.
+// Every page searches the sources at the same time; a source that fails
+// doesn't stop the others, its error is returned with the notes of the rest.
+// Repeated sentences are removed, also between pages, and the notes of a
+// page are ranked by the number of query words they contain (ties keep the
+// order of every source, interleaving them). Notes that don't match the
+// filter are skipped. It's safe to use from multiple goroutines
+type ExternalSearch struct {
+	mu      sync.Mutex
+	query   string
+	sources []ExternalSource
+	filter  ExternalFilter
+
+	// Position of the next page in every source, a source is done
+	// when it returns no notes
+	offsets []int
+	done    []bool
+
+	seen  map[string]bool
+	total int
+}
+
+// maxFilteredPages is the number of pages requested in a Next call while
+// the filter removes all the notes
+const maxFilteredPages = 5
+
+func NewExternalSearch(sources []ExternalSource, query string, filter ExternalFilter) *ExternalSearch {
+	return &ExternalSearch{
+		query:   query,
+		sources: sources,
+		filter:  filter,
+		offsets: make([]int, len(sources)),
+		done:    make([]bool, len(sources)),
+		seen:    map[string]bool{},
+	}
+}
+
+func (s *ExternalSearch) Query() string {
+	return s.query
+}
+
+// Total returns the number of notes returned until now
+func (s *ExternalSearch) Total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// Done reports whether every source has returned all its notes
+func (s *ExternalSearch) Done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.allDone()
+}
+
+func (s *ExternalSearch) allDone() bool {
+	for _, done := range s.done {
+		if !done {
+			return false
+		}
+	}
+	return true
+}
+
+// Next returns the next page, with up to n notes of every source. When
+// the filter removes all of them, the following pages are requested
+func (s *ExternalSearch) Next(ctx context.Context, n int) ([]models.Note, []error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 1; ; i++ {
+		notes, errs := s.next(ctx, n)
+		if len(notes) > 0 || len(errs) > 0 || i == maxFilteredPages || s.allDone() {
+			return notes, errs
+		}
+	}
+}
+
+func (s *ExternalSearch) next(ctx context.Context, n int) ([]models.Note, []error) {
+	results := make([][]models.Note, len(s.sources))
+	errs := make([]error, len(s.sources))
+
+	var wg sync.WaitGroup
+	for i, source := range s.sources {
+		if s.done[i] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, source ExternalSource) {
+			defer wg.Done()
+
+			start := s.offsets[i]
+			results[i], errs[i] = source.FetchNotesFromQuery(ctx, s.query, start, start+n-1)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", source.DisplayName(), errs[i])
+			}
+		}(i, source)
+	}
+	wg.Wait()
+
+	failed := []error{}
+	for i, err := range errs {
+		switch {
+		case s.done[i]:
+		case err != nil:
+			// The page is requested again next time
+			failed = append(failed, err)
+		case len(results[i]) == 0:
+			s.done[i] = true
+		default:
+			s.offsets[i] += n
+			results[i] = s.filterNotes(s.sources[i], results[i])
+		}
+	}
+
+	notes := mergeResults(results, strings.Fields(s.query), s.seen, s.total)
+	s.total += len(notes)
+	return notes, failed
+}
+
+// nsfwHider is implemented by the sources configured with hideNsfw
+type nsfwHider interface {
+	HidesNSFW() bool
+}
+
+func (s *ExternalSearch) filterNotes(source ExternalSource, notes []models.Note) []models.Note {
+	filter := s.filter
+	if hider, ok := source.(nsfwHider); ok && hider.HidesNSFW() {
+		filter.HideNSFW = true
+	}
+
+	filtered := make([]models.Note, 0, len(notes))
+	for i := range notes {
+		if filter.Match(&notes[i]) {
+			filtered = append(filtered, notes[i])
+		}
+	}
+	return filtered
+}
+
+// rankedNote is a note with the data used to sort the merged results
+type rankedNote struct {
+	note   models.Note
+	score  int
+	rank   int
+	source int
+}
+
+// mergeResults joins the notes of every source, without the sentences of
+// seen (it's updated). The notes IDs are renumbered from start, the sources
+// use their own
+func mergeResults(results [][]models.Note, words []string, seen map[string]bool, start int) []models.Note {
+	ranked := []rankedNote{}
+
+	for source, notes := range results {
+		for rank, note := range notes {
+			key := normalizeSentence(note.GetSentence())
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			score := 0
+			for _, word := range words {
+				if strings.Contains(note.GetSentence(), word) {
+					score++
+				}
+			}
+			ranked = append(ranked, rankedNote{note: note, score: score, rank: rank, source: source})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		return a.source < b.source
+	})
+
+	notes := make([]models.Note, len(ranked))
+	for i, r := range ranked {
+		notes[i] = r.note
+		notes[i].NoteID = start + i
+	}
+	return notes
+}
+
+// normalizeSentence removes spaces and punctuation, so the same sentence
+// from different sources is found
+func normalizeSentence(sentence string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, sentence)
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultSentenceColumns are the TSV columns used when the source doesn't
+// set them, the ones of Tatoeba sentence pairs exports (id, sentence, id,
+// translation)
+const DefaultSentenceColumns = "-,sentence,-,translation"
+
+// sentenceLine is a line of a JSONL sentence file
+type sentenceLine struct {
+	Sentence    string `json:"sentence"`
+	Text        string `json:"text"`
+	Translation string `json:"translation"`
+	Audio       string `json:"audio"`
+	Image       string `json:"image"`
+}
+
+// OpenSentenceCorpus indexes a file of sentences with their translation.
+// .jsonl files have an object per line (sentence or text, translation,
+// audio, image), other files are TSV with the columns separated by commas
+// (sentence, translation, audio, image, - to skip one). Relative media
+// paths are relative to the file
+func OpenSentenceCorpus(path, columns, indexDir string) (*Corpus, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if columns == "" {
+		columns = DefaultSentenceColumns
+	}
+
+	root, name := filepath.Split(path)
+	files := []corpusFile{{path: name, modTime: info.ModTime()}}
+
+	// The columns are part of the key, so changing them indexes the file again
+	return openCorpus(path+"\x00"+columns, filepath.Clean(root), files, indexDir, func(rel string) ([]CorpusEntry, error) {
+		return indexSentences(path, rel, columns)
+	})
+}
+
+func indexSentences(path, rel, columns string) ([]CorpusEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []CorpusEntry
+	if strings.EqualFold(filepath.Ext(path), ".jsonl") {
+		entries, err = parseSentencesJSONL(file)
+	} else {
+		entries, err = parseSentencesTSV(file, strings.Split(columns, ","))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("indexing %s: %w", rel, err)
+	}
+
+	title := strings.TrimSuffix(rel, filepath.Ext(rel))
+	for i := range entries {
+		entries[i].Morphs = ParseJpMorphs(entries[i].Text)
+		entries[i].Title = title
+		entries[i].File = rel
+		entries[i].Index = i + 1
+	}
+	return entries, nil
+}
+
+func parseSentencesTSV(r io.Reader, columns []string) ([]CorpusEntry, error) {
+	entries := []CorpusEntry{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "\uFEFF")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry := CorpusEntry{}
+		for i, value := range strings.Split(line, "\t") {
+			if i >= len(columns) {
+				break
+			}
+
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(columns[i]) {
+			case "sentence":
+				entry.Text = value
+			case "translation":
+				entry.Translation = value
+			case "audio":
+				entry.Audio = filepath.ToSlash(value)
+			case "image":
+				entry.Image = filepath.ToSlash(value)
+			}
+		}
+
+		if entry.Text != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func parseSentencesJSONL(r io.Reader) ([]CorpusEntry, error) {
+	entries := []CorpusEntry{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" {
+			continue
+		}
+
+		var sentence sentenceLine
+		err := json.Unmarshal([]byte(line), &sentence)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		text := sentence.Sentence
+		if text == "" {
+			text = sentence.Text
+		}
+		if text == "" {
+			continue
+		}
+
+		entries = append(entries, CorpusEntry{
+			Text:        text,
+			Translation: sentence.Translation,
+			Audio:       filepath.ToSlash(sentence.Audio),
+			Image:       filepath.ToSlash(sentence.Image),
+		})
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"math"
+
+	"github.com/gopxl/beep"
+)
+
+// timeStretch changes the speed of a stream without changing its pitch.
+// It uses WSOLA: Hann windowed frames are overlap-added every hop samples,
+// and every frame is read from the input position (around speed*hop from
+// the previous one) that best continues the previous frame waveform.
+type timeStretch struct {
+	s     beep.Streamer
+	speed float64
+
+	window    []float64
+	tolerance int
+
+	// in is the buffered input, in[0] is the input sample inOffset
+	in       [][2]float64
+	inOffset int
+	eof      bool
+
+	// nominal is the input position of the next frame without the search,
+	// prev is the input position of the previous frame (-1 at the start)
+	nominal float64
+	prev    int
+
+	acc      [][2]float64
+	out      [][2]float64
+	finished bool
+}
+
+func newTimeStretch(s beep.Streamer, speed float64, frameSize int) *timeStretch {
+	window := make([]float64, frameSize)
+	for i := range window {
+		// Periodic Hann window, two windows at 50% overlap sum 1
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize))
+	}
+
+	return &timeStretch{
+		s:         s,
+		speed:     speed,
+		window:    window,
+		tolerance: frameSize / 4,
+		prev:      -1,
+		acc:       make([][2]float64, frameSize),
+	}
+}
+
+// reset drops the buffered samples, it's used after seeking the input
+func (t *timeStretch) reset() {
+	t.in = t.in[:0]
+	t.inOffset = 0
+	t.eof = false
+	t.nominal = 0
+	t.prev = -1
+	t.out = t.out[:0]
+	t.finished = false
+	for i := range t.acc {
+		t.acc[i] = [2]float64{}
+	}
+}
+
+// fill reads the input until it has n samples buffered
+func (t *timeStretch) fill(n int) {
+	buf := make([][2]float64, 512)
+	for !t.eof && len(t.in) < n {
+		read, ok := t.s.Stream(buf)
+		t.in = append(t.in, buf[:read]...)
+		if !ok {
+			t.eof = true
+		}
+	}
+}
+
+// at returns the input sample at the absolute position pos, or silence
+func (t *timeStretch) at(pos int) [2]float64 {
+	i := pos - t.inOffset
+	if i < 0 || i >= len(t.in) {
+		return [2]float64{}
+	}
+	return t.in[i]
+}
+
+// search returns the frame position around nominal that is the most
+// similar to the natural continuation of the previous frame
+func (t *timeStretch) search(nominal int) int {
+	hop := len(t.window) / 2
+	natural := t.prev + hop
+
+	best, bestScore := nominal, math.Inf(-1)
+	for delta := -t.tolerance; delta <= t.tolerance; delta++ {
+		start := nominal + delta
+		if start < t.inOffset {
+			continue
+		}
+
+		score := 0.0
+		for i := 0; i < hop; i += 2 {
+			a, b := t.at(start+i), t.at(natural+i)
+			score += (a[0] + a[1]) * (b[0] + b[1])
+		}
+		if score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+	return best
+}
+
+// step adds the next frame and moves hop finished samples to out
+func (t *timeStretch) step() {
+	size := len(t.window)
+	hop := size / 2
+
+	nominal := int(math.Round(t.nominal))
+	t.fill(nominal + t.tolerance + size - t.inOffset)
+
+	if t.eof && nominal-t.inOffset >= len(t.in) {
+		// Flush the tail of the last frame
+		t.out = append(t.out, t.acc[:hop]...)
+		t.finished = true
+		return
+	}
+
+	start := nominal
+	if t.speed != 1 && t.prev >= 0 {
+		start = t.search(nominal)
+	}
+
+	for i := 0; i < size; i++ {
+		sample := t.at(start + i)
+		t.acc[i][0] += sample[0] * t.window[i]
+		t.acc[i][1] += sample[1] * t.window[i]
+	}
+
+	t.out = append(t.out, t.acc[:hop]...)
+	copy(t.acc, t.acc[hop:])
+	for i := size - hop; i < size; i++ {
+		t.acc[i] = [2]float64{}
+	}
+
+	t.prev = start
+	t.nominal += float64(hop) * t.speed
+
+	// Drop the input that is not needed anymore
+	keep := min(int(t.nominal)-t.tolerance, t.prev+hop)
+	if drop := keep - t.inOffset; drop > 0 {
+		if drop > len(t.in) {
+			drop = len(t.in)
+		}
+		t.in = append(t.in[:0], t.in[drop:]...)
+		t.inOffset += drop
+	}
+}
+
+func (t *timeStretch) Stream(samples [][2]float64) (n int, ok bool) {
+	for len(t.out) < len(samples) && !t.finished {
+		t.step()
+	}
+
+	n = copy(samples, t.out)
+	t.out = append(t.out[:0], t.out[n:]...)
+	return n, n > 0 || !t.finished
+}
+
+func (t *timeStretch) Err() error {
+	return t.s.Err()
+}
+
+// loopStreamer plays the stream again from the start when it ends,
+// while loop is true
+type loopStreamer struct {
+	s    beep.StreamSeeker
+	loop bool
+}
+
+func (l *loopStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		read, ok := l.s.Stream(samples[n:])
+		n += read
+		if ok && read > 0 {
+			continue
+		}
+
+		if ok || !l.loop || l.s.Len() == 0 || l.s.Seek(0) != nil {
+			return n, n > 0 || ok
+		}
+	}
+	return n, true
+}
+
+func (l *loopStreamer) Err() error {
+	return l.s.Err()
+}
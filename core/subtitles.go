@@ -0,0 +1,179 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is a line of a subtitle file. Index starts at 1 and follows the
+// order of the file
+type Cue struct {
+	Index int
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+var (
+	srtTimeRegex = regexp.MustCompile(`(\d+):(\d{2}):(\d{2})[,.](\d{1,3})\s*-->\s*(\d+):(\d{2}):(\d{2})[,.](\d{1,3})`)
+	htmlTagRegex = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	assTagRegex  = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// ParseSubtitles parses a .srt or .ass/.ssa file, the format is chosen
+// by the extension of name
+func ParseSubtitles(r io.Reader, name string) ([]Cue, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".srt":
+		return ParseSRT(r)
+	case ".ass", ".ssa":
+		return ParseASS(r)
+	}
+	return nil, fmt.Errorf("unsupported subtitles: %s", filepath.Base(name))
+}
+
+// ParseSRT parses SubRip subtitles. Formatting tags are removed
+func ParseSRT(r io.Reader) ([]Cue, error) {
+	cues := []Cue{}
+	var cue *Cue
+	var lines []string
+
+	flush := func() {
+		if cue != nil {
+			cue.Text = cleanCueText(strings.Join(lines, " "))
+			if cue.Text != "" {
+				cue.Index = len(cues) + 1
+				cues = append(cues, *cue)
+			}
+		}
+		cue, lines = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+
+		if match := srtTimeRegex.FindStringSubmatch(line); match != nil {
+			flush()
+			cue = &Cue{
+				Start: parseCueTime(match[1], match[2], match[3], match[4]),
+				End:   parseCueTime(match[5], match[6], match[7], match[8]),
+			}
+			continue
+		}
+
+		if line == "" {
+			flush()
+			continue
+		}
+		if cue != nil {
+			lines = append(lines, line)
+		}
+	}
+	flush()
+
+	return cues, scanner.Err()
+}
+
+// ParseASS parses the Dialogue lines of Advanced SubStation Alpha subtitles.
+// Override tags ({\...}) are removed
+func ParseASS(r io.Reader) ([]Cue, error) {
+	cues := []Cue{}
+
+	// Default column order, it's replaced by the Format line of the events
+	format := []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+	inEvents := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "Format":
+			format = nil
+			for _, column := range strings.Split(value, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(column)))
+			}
+
+		case "Dialogue":
+			// The text is the last column and it can have commas
+			values := strings.SplitN(value, ",", len(format))
+			if len(values) < len(format) {
+				continue
+			}
+
+			cue := Cue{}
+			for i, column := range format {
+				v := strings.TrimSpace(values[i])
+				switch column {
+				case "start":
+					cue.Start = parseASSTime(v)
+				case "end":
+					cue.End = parseASSTime(v)
+				case "text":
+					v = strings.NewReplacer(`\N`, " ", `\n`, " ", `\h`, " ").Replace(v)
+					cue.Text = cleanCueText(v)
+				}
+			}
+
+			if cue.Text != "" {
+				cue.Index = len(cues) + 1
+				cues = append(cues, cue)
+			}
+		}
+	}
+
+	return cues, scanner.Err()
+}
+
+func cleanCueText(text string) string {
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	text = assTagRegex.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func parseCueTime(hours, minutes, seconds, fraction string) time.Duration {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(seconds)
+
+	// The fraction can have 1 to 3 digits
+	for len(fraction) < 3 {
+		fraction += "0"
+	}
+	ms, _ := strconv.Atoi(fraction)
+
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second + time.Duration(ms)*time.Millisecond
+}
+
+// parseASSTime parses h:mm:ss.cc
+func parseASSTime(value string) time.Duration {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	seconds, fraction, _ := strings.Cut(parts[2], ".")
+	return parseCueTime(parts[0], parts[1], seconds, fraction)
+}
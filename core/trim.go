@@ -0,0 +1,125 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/wav"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// segment is the part of a clip between the start and end samples,
+// positions are relative to start
+type segment struct {
+	beep.StreamSeekCloser
+	start, end int
+}
+
+// newSegment limits s to the part between start and end, clamped to the clip
+func newSegment(s beep.StreamSeekCloser, start, end int) (*segment, error) {
+	end = min(end, s.Len())
+	start = max(0, min(start, end))
+
+	err := s.Seek(start)
+	if err != nil {
+		return nil, err
+	}
+	return &segment{StreamSeekCloser: s, start: start, end: end}, nil
+}
+
+func (s *segment) Stream(samples [][2]float64) (n int, ok bool) {
+	left := s.end - s.StreamSeekCloser.Position()
+	if left <= 0 {
+		return 0, false
+	}
+	if len(samples) > left {
+		samples = samples[:left]
+	}
+	return s.StreamSeekCloser.Stream(samples)
+}
+
+func (s *segment) Len() int {
+	return s.end - s.start
+}
+
+func (s *segment) Position() int {
+	return s.StreamSeekCloser.Position() - s.start
+}
+
+func (s *segment) Seek(p int) error {
+	return s.StreamSeekCloser.Seek(s.start + p)
+}
+
+// Trim decodes the note clip and encodes the part between start and end as WAV
+func (a *Audio) Trim(note *models.Note, start, end time.Duration) ([]byte, error) {
+	if end <= start {
+		return nil, errors.New("the trim end must be after the start")
+	}
+
+	reader, err := a.open(note)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		return nil, errors.New("the note has no audio")
+	}
+
+	streamer, format, err := models.DecodeAudio(reader, note.AudioRef())
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("decoding audio: %w", err)
+	}
+	defer streamer.Close()
+
+	clip, err := newSegment(streamer, format.SampleRate.N(start), format.SampleRate.N(end))
+	if err != nil {
+		return nil, fmt.Errorf("seeking audio: %w", err)
+	}
+
+	// 16 bits are enough for sentence audio
+	format.Precision = 2
+
+	w := &writeSeeker{}
+	err = wav.Encode(w, clip, format)
+	if err != nil {
+		return nil, fmt.Errorf("encoding wav: %w", err)
+	}
+	return w.buf, nil
+}
+
+// writeSeeker is an in memory io.WriteSeeker, the wav encoder seeks back
+// to write the header sizes
+type writeSeeker struct {
+	buf []byte
+	pos int
+}
+
+func (w *writeSeeker) Write(p []byte) (int, error) {
+	if end := w.pos + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	n := copy(w.buf[w.pos:], p)
+	w.pos += n
+	return n, nil
+}
+
+func (w *writeSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos := int64(w.pos)
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = int64(len(w.buf)) + offset
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	w.pos = int(pos)
+	return pos, nil
+}
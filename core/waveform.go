@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// Waveform is the peak level of every part of a clip, between 0 and 1
+type Waveform struct {
+	Peaks    []float64
+	Duration time.Duration
+}
+
+// Waveform decodes the whole note clip and splits it in bins parts.
+// It returns nil if the note has no audio
+func (a *Audio) Waveform(note *models.Note, bins int) (*Waveform, error) {
+	reader, err := a.open(note)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		return nil, nil
+	}
+
+	streamer, format, err := models.DecodeAudio(reader, note.AudioRef())
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("decoding audio: %w", err)
+	}
+	defer streamer.Close()
+
+	total := streamer.Len()
+	waveform := &Waveform{
+		Peaks:    make([]float64, bins),
+		Duration: format.SampleRate.D(total),
+	}
+	if total <= 0 || bins <= 0 {
+		return waveform, nil
+	}
+
+	buf := make([][2]float64, 4096)
+	position := 0
+	for {
+		n, ok := streamer.Stream(buf)
+		for _, sample := range buf[:n] {
+			bin := min(position*bins/total, bins-1)
+			peak := max(math.Abs(sample[0]), math.Abs(sample[1]))
+			waveform.Peaks[bin] = max(waveform.Peaks[bin], peak)
+			position++
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return nil, fmt.Errorf("decoding audio: %w", err)
+	}
+
+	for i := range waveform.Peaks {
+		waveform.Peaks[i] = min(waveform.Peaks[i], 1)
+	}
+	return waveform, nil
+}
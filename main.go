@@ -8,11 +8,24 @@ import (
 
 	"github.com/xyaman/anki-tui/core"
 	"github.com/xyaman/anki-tui/ui"
+	"github.com/xyaman/anki-tui/ui/components/image"
 )
 
 func main() {
 
-	core.App = core.NewAnkiTui()
+	app, err := core.NewAnkiTui()
+	if err != nil {
+		fmt.Printf("Error loading the config: %v\n", err)
+		os.Exit(1)
+	}
+	core.App = app
+
+	protocol, err := image.ParseProtocol(app.Config.ImageProtocol, os.Getenv)
+	if err != nil {
+		fmt.Printf("Error loading the config: %v\n", err)
+		os.Exit(1)
+	}
+	image.SetProtocol(protocol)
 
 	p := tea.NewProgram(ui.NewProgram(), tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
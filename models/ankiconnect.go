@@ -5,24 +5,24 @@ import (
 	"fmt"
 	"image"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/gopxl/beep"
-	"github.com/gopxl/beep/mp3"
-	"golang.org/x/image/webp"
+	// Images of external sources are webp
+	_ "golang.org/x/image/webp"
 )
 
+// FindNotesResult is the result of the findNotes action. AnkiConnect errors
+// are returned by the client as *core.AnkiConnectError
 type FindNotesResult struct {
-	Result []int  `json:"result"`
-	Error  string `json:"error"`
+	Result []int `json:"result"`
 }
 
+// NotesInfoResult is the result of the notesInfo action
 type NotesInfoResult struct {
 	Result []Note `json:"result"`
-	Error  string `json:"error"`
 }
 
 type Note struct {
@@ -37,13 +37,51 @@ type Note struct {
 	AudioValue    string
 	ImageValue    string
 
+	// TranslationValue is the translation of the sentence, external
+	// sources can have it
+	TranslationValue string
+
 	Image    image.Image
 	Filename string
+
+	// MediaSource is the external source of the note, nil for Anki notes
+	MediaSource MediaSource `json:"-"`
+
+	// Metadata of the sentence in its external source
+	Metadata NoteMetadata `json:"-"`
+
+	// Selected audio and image reference, a field can have more than one
+	AudioIndex int
+	ImageIndex int
+
+	// AudioTrim is the part of the selected audio that is mined,
+	// nil to mine the whole clip
+	AudioTrim *TrimRange
+}
+
+// TrimRange is a part of a clip
+type TrimRange struct {
+	Start time.Duration
+	End   time.Duration
 }
 
 // Fields represents the main fields for a Anki Note
 type Fields map[string]interface{}
 
+// NewNote is a note to be created with the addNote action
+type NewNote struct {
+	DeckName  string            `json:"deckName"`
+	ModelName string            `json:"modelName"`
+	Fields    map[string]string `json:"fields"`
+	Tags      []string          `json:"tags"`
+	Options   NewNoteOptions    `json:"options"`
+}
+
+type NewNoteOptions struct {
+	AllowDuplicate bool   `json:"allowDuplicate"`
+	DuplicateScope string `json:"duplicateScope,omitempty"`
+}
+
 func (n *Note) GetFieldsValues(sentence, morphs, audio, image string) {
 	sentenceFieldsName := strings.Split(sentence, ",")
 	for _, fieldName := range sentenceFieldsName {
@@ -89,6 +127,11 @@ func (n *Note) GetSentence() string {
 	return n.SentenceValue
 }
 
+// GetTranslation returns the translation of the sentence, or "" if there is none
+func (n *Note) GetTranslation() string {
+	return n.TranslationValue
+}
+
 func (n *Note) GetMorphs() string {
 	return n.MorphsValue
 }
@@ -97,144 +140,182 @@ func (n *Note) GetAudioValue() string {
 	return n.AudioValue
 }
 
-func (n *Note) GetAudio(mediaCollection string) (io.ReadCloser, beep.StreamSeekCloser) {
-	if n.GetSource() == "BrigadaSOS" {
-		res, err := http.Get(n.GetAudioValue())
-		if err != nil {
-			panic(err)
-		}
+// AudioRefs returns the audio files of the note. External sources
+// have a single audio URL
+func (n *Note) AudioRefs() []string {
+	if n.GetAudioValue() == "" {
+		return []string{}
+	}
+	if n.IsExternal() {
+		return []string{n.GetAudioValue()}
+	}
+	return ParseSoundRefs(n.GetAudioValue())
+}
 
-		// defer res.Body.Close()
-		streamer, _, err := mp3.Decode(res.Body)
-		if err != nil {
-			panic(err)
-		}
+// ImageRefs returns the image files of the note. External notes
+// have a single image
+func (n *Note) ImageRefs() []string {
+	if n.GetImageValue() == "" {
+		return []string{}
+	}
+	if n.IsExternal() {
+		return []string{n.GetImageValue()}
+	}
+	return ParseImageRefs(n.GetImageValue())
+}
 
-		return res.Body, streamer
+// AudioRef returns the selected audio file, or "" if there is none
+func (n *Note) AudioRef() string {
+	refs := n.AudioRefs()
+	if len(refs) == 0 {
+		return ""
 	}
+	return refs[n.AudioIndex%len(refs)]
+}
 
-	if n.GetAudioValue() == "" {
-		return nil, nil
+// ImageRef returns the selected image file, or "" if there is none
+func (n *Note) ImageRef() string {
+	refs := n.ImageRefs()
+	if len(refs) == 0 {
+		return ""
 	}
+	return refs[n.ImageIndex%len(refs)]
+}
 
-	audioPath := n.GetAudioValue()[7 : len(n.GetAudioValue())-1]
-	reader, err := os.Open(filepath.Clean(filepath.Join(mediaCollection, audioPath)))
-	if err != nil {
-		panic(err)
+// NextAudio selects the next audio file, it returns false if there
+// is only one
+func (n *Note) NextAudio() bool {
+	refs := n.AudioRefs()
+	if len(refs) < 2 {
+		return false
 	}
+	n.AudioIndex = (n.AudioIndex + 1) % len(refs)
+	n.AudioTrim = nil
+	return true
+}
 
-	// defer reader.Close()
-	streamer, _, err := mp3.Decode(reader)
-	if err != nil {
-		panic(err)
+// NextImage selects the next image file, it returns false if there
+// is only one
+func (n *Note) NextImage() bool {
+	refs := n.ImageRefs()
+	if len(refs) < 2 {
+		return false
 	}
-	return reader, streamer
+	n.ImageIndex = (n.ImageIndex + 1) % len(refs)
+	n.Image = nil
+	return true
+}
+
+// IsRemote reports whether a media reference is an URL
+func IsRemote(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
+
+// IsExternal reports whether the note comes from an external source
+func (n *Note) IsExternal() bool {
+	return n.MediaSource != nil
+}
+
+// OpenAudio opens the selected audio file of the note, it needs to be
+// decoded with DecodeAudio. It returns nil if the note has no audio
+func (n *Note) OpenAudio(mediaCollection string) (io.ReadCloser, error) {
+	audioRef := n.AudioRef()
+	if audioRef == "" {
+		return nil, nil
+	}
+
+	if n.IsExternal() {
+		return n.MediaSource.OpenAudio(n)
+	}
+	if IsRemote(audioRef) {
+		return OpenURL(audioRef)
+	}
+
+	return os.Open(filepath.Join(mediaCollection, filepath.Base(audioRef)))
 }
 
 func (n *Note) GetImageValue() string {
 	return n.ImageValue
 }
 
-func (n *Note) GetImage(mediaCollection string) image.Image {
+// GetImage opens and decodes the note image, it returns nil if the
+// note has no image. Decoded images are cached by core.Images
+func (n *Note) GetImage(mediaCollection string) (image.Image, error) {
 	if n.Image != nil {
-		return n.Image
+		return n.Image, nil
 	}
 
-	// TODO: Add this method to the interface
-	if n.GetSource() == "BrigadaSOS" {
-		res, err := http.Get(n.GetImageValue())
-		if err != nil {
-			panic(err)
-		}
-		defer res.Body.Close()
-		img, err := webp.Decode(res.Body)
-		if err != nil {
-			panic(err)
-		}
-
-		n.Image = img
-		return img
+	imageRef := n.ImageRef()
+	if imageRef == "" {
+		return nil, nil
 	}
 
-	imageValue := n.GetImageValue()
-	if imageValue == "" {
-		return nil
+	var imageContent io.ReadCloser
+	var err error
+	if n.IsExternal() {
+		imageContent, err = n.MediaSource.OpenImage(n)
+	} else {
+		imageContent, err = os.Open(filepath.Join(mediaCollection, filepath.Base(imageRef)))
 	}
-
-	imageContent, err := os.Open(filepath.Clean(filepath.Join(mediaCollection, imageValue[10:len(imageValue)-2])))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer imageContent.Close()
 
 	img, _, err := image.Decode(imageContent)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("decoding image: %w", err)
 	}
-
-	return img
+	return img, nil
 }
 
 func (n *Note) GetFilename() string {
 	return n.Filename
 }
 
-// DownloadImage saves the image to the media collection and returns the path
-func (n *Note) DownloadImage(collectionPath string) (string, error) {
-
-	var imagePath string
-	// TODO implement this method in the interface
-	if n.GetSource() == "BrigadaSOS" {
-
-		res, err := http.Get(n.GetImageValue())
-		if err != nil {
-			return "", err
-		}
+// MediaStore saves media files in the Anki collection. The returned
+// filename is the one chosen by Anki, it can differ from the requested one
+type MediaStore interface {
+	StoreMediaFile(filename string, data []byte) (string, error)
+	StoreMediaFileFromURL(filename string, url string) (string, error)
+}
 
-		defer res.Body.Close()
-		imagePath = filepath.Join(collectionPath, n.GetFilename()+".webp")
-		file, err := os.Create(imagePath)
-		if err != nil {
-			return "", err
-		}
+// DownloadImage saves the image to the media collection and returns the
+// image field value
+func (n *Note) DownloadImage(store MediaStore) (string, error) {
+	if n.GetImageValue() == "" {
+		return "", errors.New("Note image is nil")
+	}
+	if !n.IsExternal() {
+		return "", fmt.Errorf("can't download images from %s", n.GetSource())
+	}
 
-		_, err = io.Copy(file, res.Body)
-		if err != nil {
-			return "", err
-		}
+	filename, err := n.MediaSource.DownloadImage(n, store)
+	if err != nil {
+		return "", err
 	}
 
 	// Anki field format: <img src="image.jpg">
-	imageFieldValue := fmt.Sprintf("<img src=\"%s\">", filepath.Base(imagePath))
+	imageFieldValue := fmt.Sprintf("<img src=\"%s\">", filename)
 	return imageFieldValue, nil
 }
 
-func (n *Note) DownloadAudio(collectionPath string) (string, error) {
+// DownloadAudio saves the audio to the media collection and returns the
+// audio field value
+func (n *Note) DownloadAudio(store MediaStore) (string, error) {
 	if n.GetAudioValue() == "" {
 		return "", errors.New("Note audio is nil")
 	}
+	if !n.IsExternal() {
+		return "", fmt.Errorf("can't download audio from %s", n.GetSource())
+	}
 
-	var audioPath string
-	// TODO implement this method in the interface
-	if n.GetSource() == "BrigadaSOS" {
-		res, err := http.Get(n.GetAudioValue())
-		if err != nil {
-			return "", err
-		}
-
-		defer res.Body.Close()
-		audioPath = filepath.Join(collectionPath, n.GetFilename()+".mp3")
-		file, err := os.Create(audioPath)
-		if err != nil {
-			return "", err
-		}
-
-		_, err = io.Copy(file, res.Body)
-		if err != nil {
-			return "", err
-		}
+	filename, err := n.MediaSource.DownloadAudio(n, store)
+	if err != nil {
+		return "", err
 	}
 
 	// Anki field format: [sound:audio.mp3]
-	audioFieldValue := fmt.Sprintf("[sound:%s]", filepath.Base(audioPath))
+	audioFieldValue := fmt.Sprintf("[sound:%s]", filename)
 	return audioFieldValue, nil
 }
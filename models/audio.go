@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/flac"
+	"github.com/gopxl/beep/mp3"
+	"github.com/gopxl/beep/vorbis"
+	"github.com/gopxl/beep/wav"
+)
+
+type AudioFormat string
+
+const (
+	UnknownAudio AudioFormat = ""
+	MP3Audio     AudioFormat = "mp3"
+	VorbisAudio  AudioFormat = "vorbis"
+	OpusAudio    AudioFormat = "opus"
+	WavAudio     AudioFormat = "wav"
+	FlacAudio    AudioFormat = "flac"
+)
+
+// headerSize is the number of bytes needed to detect the audio format
+const headerSize = 64
+
+var ErrUnsupportedAudio = errors.New("unsupported audio format")
+
+// ErrOpusAudio is returned for Opus files, there isn't a pure Go decoder
+// for them yet
+var ErrOpusAudio = fmt.Errorf("%w: opus", ErrUnsupportedAudio)
+
+// DetectAudioFormat detects the format from the first bytes of the file,
+// if they are not conclusive the extension of name is used
+func DetectAudioFormat(header []byte, name string) AudioFormat {
+	switch {
+	case bytes.HasPrefix(header, []byte("OggS")):
+		// Ogg is a container, the codec id is in the first packet
+		if bytes.Contains(header, []byte("OpusHead")) {
+			return OpusAudio
+		}
+		return VorbisAudio
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FlacAudio
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return WavAudio
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return MP3Audio
+	// MPEG frame sync, layer bits 00 is AAC
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 != 0:
+		return MP3Audio
+	}
+
+	// URLs can have a query
+	name = strings.SplitN(name, "?", 2)[0]
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mp3":
+		return MP3Audio
+	case ".ogg", ".oga":
+		return VorbisAudio
+	case ".opus":
+		return OpusAudio
+	case ".wav":
+		return WavAudio
+	case ".flac":
+		return FlacAudio
+	}
+
+	return UnknownAudio
+}
+
+// readCloser joins a buffered reader with the closer of the original reader
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// DecodeAudio decodes mp3, ogg vorbis, wav and flac files. The format is
+// detected by DetectAudioFormat, name is the filename or URL of the audio.
+// Closing the streamer closes rc.
+func DecodeAudio(rc io.ReadCloser, name string) (beep.StreamSeekCloser, beep.Format, error) {
+	header := make([]byte, headerSize)
+
+	// Seekable readers (files) are rewinded, so the decoders can seek them
+	if seeker, ok := rc.(io.ReadSeeker); ok {
+		n, err := io.ReadFull(seeker, header)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, beep.Format{}, err
+		}
+		header = header[:n]
+
+		_, err = seeker.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, beep.Format{}, err
+		}
+	} else {
+		buffered := bufio.NewReaderSize(rc, headerSize)
+		header, _ = buffered.Peek(headerSize)
+		rc = readCloser{Reader: buffered, Closer: rc}
+	}
+
+	format := DetectAudioFormat(header, name)
+	switch format {
+	case MP3Audio:
+		return mp3.Decode(rc)
+	case VorbisAudio:
+		return vorbis.Decode(rc)
+	case WavAudio:
+		return wav.Decode(rc)
+	case FlacAudio:
+		return flac.Decode(rc)
+	case OpusAudio:
+		return nil, beep.Format{}, fmt.Errorf("%w (%s)", ErrOpusAudio, path.Base(name))
+	}
+
+	return nil, beep.Format{}, fmt.Errorf("%w: %s", ErrUnsupportedAudio, path.Base(name))
+}
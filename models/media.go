@@ -0,0 +1,89 @@
+package models
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type MediaKind int
+
+const (
+	SoundMedia MediaKind = iota
+	ImageMedia
+)
+
+// MediaRef is a reference to a media file of the collection found in a field
+type MediaRef struct {
+	Kind     MediaKind
+	Filename string
+}
+
+var (
+	soundRefRegex = regexp.MustCompile(`\[sound:([^\]]+)\]`)
+	imgTagRegex   = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	srcAttrRegex  = regexp.MustCompile(`(?is)\bsrc\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// ParseMediaRefs returns every sound ([sound:file]) and image (<img src="file">)
+// reference of a field value, in the order they appear
+func ParseMediaRefs(value string) []MediaRef {
+	type position struct {
+		index int
+		ref   MediaRef
+	}
+
+	positions := []position{}
+	for _, match := range soundRefRegex.FindAllStringSubmatchIndex(value, -1) {
+		filename := cleanFilename(value[match[2]:match[3]])
+		if filename != "" {
+			positions = append(positions, position{match[0], MediaRef{Kind: SoundMedia, Filename: filename}})
+		}
+	}
+
+	for _, match := range imgTagRegex.FindAllStringIndex(value, -1) {
+		src := srcAttrRegex.FindStringSubmatch(value[match[0]:match[1]])
+		if src == nil {
+			continue
+		}
+
+		filename := cleanFilename(src[1] + src[2] + src[3])
+		if filename != "" {
+			positions = append(positions, position{match[0], MediaRef{Kind: ImageMedia, Filename: filename}})
+		}
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].index < positions[j].index })
+
+	refs := make([]MediaRef, len(positions))
+	for i, p := range positions {
+		refs[i] = p.ref
+	}
+	return refs
+}
+
+// ParseSoundRefs returns the filenames of the sound references of a field value
+func ParseSoundRefs(value string) []string {
+	return filterRefs(ParseMediaRefs(value), SoundMedia)
+}
+
+// ParseImageRefs returns the filenames of the image references of a field value
+func ParseImageRefs(value string) []string {
+	return filterRefs(ParseMediaRefs(value), ImageMedia)
+}
+
+func filterRefs(refs []MediaRef, kind MediaKind) []string {
+	filenames := []string{}
+	for _, ref := range refs {
+		if ref.Kind == kind {
+			filenames = append(filenames, ref.Filename)
+		}
+	}
+	return filenames
+}
+
+// cleanFilename unescapes the html entities of a filename
+func cleanFilename(filename string) string {
+	return strings.TrimSpace(html.UnescapeString(filename))
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MediaSource gives access to the media of the notes of an external
+// source. Notes keep their source, so the models don't depend on the
+// sources that exist
+type MediaSource interface {
+	// OpenImage opens the selected image of the note
+	OpenImage(note *Note) (io.ReadCloser, error)
+	// OpenAudio opens the selected audio of the note
+	OpenAudio(note *Note) (io.ReadCloser, error)
+
+	// DownloadImage saves the selected image in the collection and
+	// returns its filename
+	DownloadImage(note *Note, store MediaStore) (string, error)
+	// DownloadAudio saves the selected audio in the collection and
+	// returns its filename
+	DownloadAudio(note *Note, store MediaStore) (string, error)
+}
+
+// NoteMetadata describes where a sentence of an external source comes
+// from. The fields a source doesn't know are empty
+type NoteMetadata struct {
+	// Title of the show, Title is the original one
+	Title   string
+	TitleEn string
+
+	// Speakers are the names of the speaker, in every language the
+	// source has
+	Speakers []string
+
+	// Start and End of the sentence in the episode
+	Start time.Duration
+	End   time.Duration
+
+	NSFW bool
+}
+
+// Speaker returns the names of the speaker separated by slashes
+func (m NoteMetadata) Speaker() string {
+	return strings.Join(m.Speakers, " / ")
+}
+
+// DisplayTitle returns the title, with the english one when it's different
+func (m NoteMetadata) DisplayTitle() string {
+	if m.TitleEn == "" || m.TitleEn == m.Title {
+		return m.Title
+	}
+	if m.Title == "" {
+		return m.TitleEn
+	}
+	return fmt.Sprintf("%s (%s)", m.Title, m.TitleEn)
+}
+
+// OpenURL requests a media file, non 200 responses are errors
+func OpenURL(url string) (io.ReadCloser, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("media request failed: %s", res.Status)
+	}
+	return res.Body, nil
+}
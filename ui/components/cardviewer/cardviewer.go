@@ -3,6 +3,7 @@ package cardviewer
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	tea "github.com/charmbracelet/bubbletea"
@@ -20,12 +21,22 @@ type Model struct {
 	Note      *models.Note
 	imagepath string
 
+	// Waveform of the note clip, waveformRef is the clip it belongs to
+	waveform    *core.Waveform
+	waveformRef string
+
 	// Pitch
 	// TODO: Make it private
 	PitchMode     bool
 	PitchCursor   int
 	PitchDrops    []int
 	PitchSentence string
+
+	// Trim mode, the in and out points are marked on the waveform
+	TrimMode   bool
+	trimMarker time.Duration
+	trimIn     time.Duration
+	trimOut    time.Duration
 }
 
 // New creates a new image model
@@ -47,6 +58,36 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	image, cmd := m.Image.Update(msg)
 	m.Image = image
 
+	if msg, ok := msg.(WaveformMsg); ok {
+		if msg.Err != nil {
+			return m, core.Log(core.InfoLog{Type: "error", Text: "Waveform: " + msg.Err.Error(), Seconds: 3})
+		}
+		m.waveform = msg.Waveform
+		m.waveformRef = msg.Ref
+		return m, nil
+	}
+
+	if m.TrimMode {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "h":
+				m.moveTrimMarker(-trimStep)
+			case "l":
+				m.moveTrimMarker(trimStep)
+			case "H":
+				m.moveTrimMarker(-trimBigStep)
+			case "L":
+				m.moveTrimMarker(trimBigStep)
+			case ",":
+				m.trimIn = min(m.trimMarker, m.trimOut)
+			case ".":
+				m.trimOut = max(m.trimMarker, m.trimIn)
+			}
+		}
+		return m, cmd
+	}
+
 	// TODO: we already have the whole note information,
 	// so we could mine from here directly
 	switch msg := msg.(type) {
@@ -54,7 +95,10 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		switch msg.String() {
 		// See card in anki
 		case "g":
-			core.App.AnkiConnect.GuiBrowse(fmt.Sprintf("nid:%d", m.Note.NoteID))
+			err := core.App.AnkiConnect.GuiBrowse(fmt.Sprintf("nid:%d", m.Note.NoteID))
+			if err != nil {
+				return m, core.Log(core.InfoLog{Type: "error", Text: err.Error(), Seconds: 3})
+			}
 
 		// Enter/exit pitch mode
 		// It will parse the sentence if is not parsed yet
@@ -111,7 +155,63 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
+// detailsWidth is the width of the image and the sentence. It has to be
+// multiple of 3 (ideally, because of japanese characters)
+const detailsWidth = 99
+
 func (m Model) View() string {
+	width := detailsWidth
+	height := core.App.AvailableHeight - lipgloss.Height(m.help.View(HelpKeys))
+
+	// Center image, but align left image and text
+	b := lipgloss.JoinVertical(
+		lipgloss.Top,
+		lipgloss.PlaceHorizontal(width, lipgloss.Center, m.Image.View()),
+		lipgloss.JoinVertical(lipgloss.Top, m.details(width)...),
+	)
+
+	var info string
+	if m.showsInfo() {
+		info = "Note\n"
+	}
+
+	renderImage := b
+	main := lipgloss.Place(core.App.AvailableWidth, height, lipgloss.Center, lipgloss.Center, info+renderImage)
+
+	return lipgloss.JoinVertical(lipgloss.Top, main, m.help.View(HelpKeys))
+}
+
+// Layout is what moves the image in the view: the size of the text around
+// it and of the screen
+type Layout struct {
+	DetailsWidth    int
+	DetailsHeight   int
+	Info            bool
+	AvailableWidth  int
+	AvailableHeight int
+}
+
+// ImageLayout returns the layout of the view, the image is drawn in the
+// same place while it doesn't change
+func (m Model) ImageLayout() Layout {
+	width, height := lipgloss.Size(lipgloss.JoinVertical(lipgloss.Top, m.details(detailsWidth)...))
+	return Layout{
+		DetailsWidth:    width,
+		DetailsHeight:   height,
+		Info:            m.showsInfo(),
+		AvailableWidth:  core.App.AvailableWidth,
+		AvailableHeight: core.App.AvailableHeight,
+	}
+}
+
+// showsInfo reports whether the info line is shown above the image
+func (m Model) showsInfo() bool {
+	return m.PitchMode && m.PitchCursor-3 >= 0
+}
+
+// details returns the lines shown below the image, the sentence is cut
+// at width
+func (m Model) details(width int) []string {
 	sentence := m.Note.GetSentence()
 	morphs := m.Note.GetMorphs()
 	if morphs == "" {
@@ -142,30 +242,240 @@ func (m Model) View() string {
 		newSentence += "\n"
 	}
 
-	width := 99 // It has to be multiple of 3 (ideally, because of japanese characters)
-	height := core.App.AvailableHeight - lipgloss.Height(m.help.View(HelpKeys))
-
 	// if sentence is longer than the width, edit sentence and add ... at the end
 	if len(sentence) > width {
 		sentence = sentence[:width-3] + "[...]"
 	}
 
-	// Center image, but align left image and text
-	b := lipgloss.JoinVertical(
-		lipgloss.Top,
-		lipgloss.PlaceHorizontal(width, lipgloss.Center, m.Image.View()),
-		lipgloss.JoinVertical(lipgloss.Top, "morphs: "+morphs, "sentence: "+sentence, newSentence, "tags: "+strings.Join(m.Note.Tags, ", ")),
-	)
+	details := []string{"morphs: " + morphs, "sentence: " + sentence, newSentence}
+	if !m.Note.IsExternal() || len(m.Note.Tags) > 0 {
+		details = append(details, "tags: "+strings.Join(m.Note.Tags, ", "))
+	}
 
-	var info string
-	if m.PitchMode && m.PitchCursor-3 >= 0 {
-		info = "Note\n"
+	if translation := m.Note.GetTranslation(); translation != "" {
+		details = append(details, "translation: "+translation)
 	}
 
-	renderImage := b
-	main := lipgloss.Place(core.App.AvailableWidth, height, lipgloss.Center, lipgloss.Center, info+renderImage)
+	details = append(details, metadataView(m.Note.Metadata)...)
 
-	return lipgloss.JoinVertical(lipgloss.Top, main, m.help.View(HelpKeys))
+	// Show the selected media when a field has more than one
+	audios, images := len(m.Note.AudioRefs()), len(m.Note.ImageRefs())
+	if audios > 1 || images > 1 {
+		media := fmt.Sprintf("media: audio %d/%d, image %d/%d", m.Note.AudioIndex%max(audios, 1)+1, audios, m.Note.ImageIndex%max(images, 1)+1, images)
+		details = append(details, media)
+	}
+
+	if m.waveform != nil && m.waveformRef == waveformRef(m.Note) {
+		details = append(details, m.waveformView())
+	}
+
+	if m.TrimMode {
+		trim := fmt.Sprintf("trim: in %s  out %s  marker %s", formatDuration(m.trimIn), formatDuration(m.trimOut), formatDuration(m.trimMarker))
+		details = append(details, trim, trimHelp)
+	} else if m.Note.AudioTrim != nil {
+		details = append(details, fmt.Sprintf("trim: %s - %s", formatDuration(m.Note.AudioTrim.Start), formatDuration(m.Note.AudioTrim.End)))
+	}
+
+	if playback := playbackView(m.Note); playback != "" {
+		details = append(details, playback)
+	}
+
+	return details
+}
+
+// metadataView returns a line of every known metadata of an external note
+func metadataView(metadata models.NoteMetadata) []string {
+	lines := []string{}
+	if title := metadata.DisplayTitle(); title != "" {
+		lines = append(lines, "title: "+title)
+	}
+	if speaker := metadata.Speaker(); speaker != "" {
+		lines = append(lines, "speaker: "+speaker)
+	}
+	if metadata.End > 0 {
+		lines = append(lines, fmt.Sprintf("time: %s - %s", formatDuration(metadata.Start), formatDuration(metadata.End)))
+	}
+	if metadata.NSFW {
+		lines = append(lines, "nsfw")
+	}
+	return lines
+}
+
+// progressWidth is the width of the playback progress bar
+const progressWidth = 30
+
+// playbackView returns the progress of the note clip, or an empty string
+// if the clip playing is from another note
+func playbackView(note *models.Note) string {
+	status := core.App.Audio.Status()
+	if status.Duration == 0 || status.NoteID != note.NoteID || status.AudioRef != note.AudioRef() {
+		return ""
+	}
+
+	state := "▶"
+	if status.Paused {
+		state = "⏸"
+	} else if !status.Playing {
+		state = "■"
+	}
+
+	filled := int(float64(progressWidth) * float64(status.Position) / float64(status.Duration))
+	filled = max(0, min(filled, progressWidth))
+	bar := strings.Repeat("━", filled) + lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(strings.Repeat("━", progressWidth-filled))
+
+	view := fmt.Sprintf("%s %s %s / %s  x%.1f  vol %+.1f", state, bar, formatDuration(status.Position), formatDuration(status.Duration), status.Speed, status.Volume)
+	if status.Loop {
+		view += "  loop"
+	}
+	return view
+}
+
+// formatDuration formats d as m:ss.d
+func formatDuration(d time.Duration) string {
+	tenths := int(d.Round(100*time.Millisecond) / (100 * time.Millisecond))
+	return fmt.Sprintf("%d:%02d.%d", tenths/600, tenths/10%60, tenths%10)
+}
+
+// waveformBins is the width of the waveform
+const waveformBins = 60
+
+var waveformLevels = []rune("▁▂▃▄▅▆▇█")
+
+// WaveformMsg is sent when the waveform of a clip is decoded
+type WaveformMsg struct {
+	Ref      string
+	Waveform *core.Waveform
+	Err      error
+}
+
+// waveformRef identifies the clip of the note, so an old waveform is not shown
+// after moving to another note or audio
+func waveformRef(note *models.Note) string {
+	return fmt.Sprintf("%d:%s", note.NoteID, note.AudioRef())
+}
+
+// LoadWaveform decodes the waveform of the note clip in the background.
+// It returns nil if it's already loaded or the note has no audio
+func (m Model) LoadWaveform() tea.Cmd {
+	if m.Note == nil || m.Note.AudioRef() == "" {
+		return nil
+	}
+
+	ref := waveformRef(m.Note)
+	if m.waveform != nil && m.waveformRef == ref {
+		return nil
+	}
+
+	note := *m.Note
+	return func() tea.Msg {
+		waveform, err := core.App.Audio.Waveform(&note, waveformBins)
+		return WaveformMsg{Ref: ref, Waveform: waveform, Err: err}
+	}
+}
+
+// waveformView renders the waveform with the clip duration. The played part
+// is highlighted and the playhead follows the playback
+func (m Model) waveformView() string {
+	peaks := m.waveform.Peaks
+
+	loudest := 0.0
+	for _, peak := range peaks {
+		loudest = max(loudest, peak)
+	}
+
+	// bin returns the waveform bin of a clip position
+	bin := func(position time.Duration) int {
+		if m.waveform.Duration == 0 {
+			return 0
+		}
+		return int(float64(len(peaks)) * float64(position) / float64(m.waveform.Duration))
+	}
+
+	playhead := -1
+	status := core.App.Audio.Status()
+	if status.Duration > 0 && status.NoteID == m.Note.NoteID && status.AudioRef == m.Note.AudioRef() {
+		playhead = bin(status.Offset + status.Position)
+	}
+
+	// The parts outside of the trim are dimmed
+	first, last, marker := 0, len(peaks)-1, -1
+	if m.TrimMode {
+		first, last, marker = bin(m.trimIn), bin(m.trimOut), bin(m.trimMarker)
+	} else if m.Note.AudioTrim != nil {
+		first, last = bin(m.Note.AudioTrim.Start), bin(m.Note.AudioTrim.End)
+	}
+
+	played := lipgloss.NewStyle().Foreground(lipgloss.Color("57"))
+	head := lipgloss.NewStyle().Foreground(lipgloss.Color("229"))
+	rest := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	cut := lipgloss.NewStyle().Foreground(lipgloss.Color("237"))
+	markerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+
+	var b strings.Builder
+	for i, peak := range peaks {
+		level := 0
+		if loudest > 0 {
+			level = int(peak / loudest * float64(len(waveformLevels)-1))
+		}
+
+		c := string(waveformLevels[level])
+		switch {
+		case i == marker:
+			b.WriteString(markerStyle.Render(c))
+		case i == playhead:
+			b.WriteString(head.Render(c))
+		case i < first || i > last:
+			b.WriteString(cut.Render(c))
+		case i < playhead:
+			b.WriteString(played.Render(c))
+		default:
+			b.WriteString(rest.Render(c))
+		}
+	}
+
+	return fmt.Sprintf("%s %s", b.String(), formatDuration(m.waveform.Duration))
+}
+
+// Trim marker steps
+const (
+	trimStep    = 100 * time.Millisecond
+	trimBigStep = time.Second
+)
+
+const trimHelp = "h/l move, H/L move 1s, , set in, . set out, p preview, enter save, esc cancel"
+
+// StartTrim enters the trim mode, it returns false if the waveform of
+// the clip is not loaded yet
+func (m *Model) StartTrim() bool {
+	if m.Note == nil || m.waveform == nil || m.waveformRef != waveformRef(m.Note) {
+		return false
+	}
+
+	m.TrimMode = true
+	m.PitchMode = false
+	m.trimIn, m.trimOut = 0, m.waveform.Duration
+	if m.Note.AudioTrim != nil {
+		m.trimIn, m.trimOut = m.Note.AudioTrim.Start, m.Note.AudioTrim.End
+	}
+	m.trimMarker = m.trimIn
+	return true
+}
+
+// Trim returns the marked part of the clip
+func (m Model) Trim() (start, end time.Duration) {
+	return m.trimIn, m.trimOut
+}
+
+// TrimRange returns the marked part of the clip, or nil if it's the whole clip
+func (m Model) TrimRange() *models.TrimRange {
+	if m.trimIn == 0 && m.trimOut == m.waveform.Duration {
+		return nil
+	}
+	return &models.TrimRange{Start: m.trimIn, End: m.trimOut}
+}
+
+func (m *Model) moveTrimMarker(offset time.Duration) {
+	m.trimMarker = max(0, min(m.trimMarker+offset, m.waveform.Duration))
 }
 
 func (m *Model) SetNote(note *models.Note) {
@@ -174,4 +484,5 @@ func (m *Model) SetNote(note *models.Note) {
 	m.PitchCursor = 0
 	m.PitchDrops = []int{}
 	m.PitchSentence = ""
+	m.TrimMode = false
 }
@@ -8,10 +8,23 @@ type HelpKeyMap struct {
 	PrevNote  key.Binding
 	OpenNote  key.Binding
 	PlayAudio key.Binding
+	NextAudio key.Binding
+	NextImage key.Binding
 	SeeInAnki key.Binding
 	Mine      key.Binding
+	NewCard   key.Binding
+	Target    key.Binding
 	Pitch     key.Binding
 	Return    key.Binding
+
+	// Playback
+	Pause  key.Binding
+	Replay key.Binding
+	Seek   key.Binding
+	Speed  key.Binding
+	Volume key.Binding
+	Loop   key.Binding
+	Trim   key.Binding
 }
 
 func (k HelpKeyMap) ShortHelp() []key.Binding {
@@ -21,7 +34,9 @@ func (k HelpKeyMap) ShortHelp() []key.Binding {
 func (k HelpKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		k.ShortHelp(),
-		{k.Pitch, k.SeeInAnki},
+		{k.NewCard, k.Target, k.Pitch, k.SeeInAnki},
+		{k.NextAudio, k.NextImage},
+		{k.Pause, k.Replay, k.Seek, k.Speed, k.Volume, k.Loop, k.Trim},
 	}
 }
 
@@ -38,10 +53,26 @@ var HelpKeys = HelpKeyMap{
 		key.WithKeys("p"),
 		key.WithHelp("p", "Play audio"),
 	),
+	NextAudio: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "Next audio"),
+	),
+	NextImage: key.NewBinding(
+		key.WithKeys("I"),
+		key.WithHelp("I", "Next image"),
+	),
 	Mine: key.NewBinding(
 		key.WithKeys("ctrl-n"),
 		key.WithHelp("ctrl-n", "Mine to Anki"),
 	),
+	NewCard: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "Create new card"),
+	),
+	Target: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "Choose minning target"),
+	),
 	OpenNote: key.NewBinding(
 		key.WithKeys("o"),
 		key.WithHelp("o", "Open note"),
@@ -58,4 +89,32 @@ var HelpKeys = HelpKeyMap{
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "Return"),
 	),
+	Pause: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "Pause/resume"),
+	),
+	Replay: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "Replay"),
+	),
+	Seek: key.NewBinding(
+		key.WithKeys("left", "right"),
+		key.WithHelp("←/→", "Seek 3s"),
+	),
+	Speed: key.NewBinding(
+		key.WithKeys("[", "]"),
+		key.WithHelp("[/]", "Speed"),
+	),
+	Volume: key.NewBinding(
+		key.WithKeys("-", "="),
+		key.WithHelp("-/=", "Volume"),
+	),
+	Loop: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "Loop"),
+	),
+	Trim: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "Trim audio"),
+	),
 }
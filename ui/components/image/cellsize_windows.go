@@ -0,0 +1,7 @@
+package image
+
+// cellSize returns the size in pixels of a terminal cell, the Windows
+// console doesn't report it
+func cellSize() (int, int) {
+	return defaultCellWidth, defaultCellHeight
+}
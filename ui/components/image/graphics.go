@@ -0,0 +1,245 @@
+package image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Protocol is the way images are drawn in the terminal
+type Protocol int
+
+const (
+	// HalfBlocks draws two pixels per cell with ▀, it works everywhere
+	HalfBlocks Protocol = iota
+	Kitty
+	Sixel
+	ITerm2
+)
+
+var protocolNames = map[string]Protocol{
+	"halfblocks": HalfBlocks,
+	"kitty":      Kitty,
+	"sixel":      Sixel,
+	"iterm2":     ITerm2,
+}
+
+// Size of a terminal cell when the terminal doesn't report it
+const (
+	defaultCellWidth  = 10
+	defaultCellHeight = 20
+)
+
+// protocol is the protocol used by all the images, see SetProtocol
+var protocol = HalfBlocks
+
+// SetProtocol sets the protocol of all the images, it has to be called
+// before any image is shown
+func SetProtocol(p Protocol) {
+	protocol = p
+}
+
+// UsesGraphics reports whether images are drawn with a graphics protocol,
+// so they have to be placed with a Screen
+func UsesGraphics() bool {
+	return protocol != HalfBlocks
+}
+
+// ParseProtocol parses the imageProtocol of the config. "auto" or an empty
+// value detects the protocol of the terminal with getenv
+func ParseProtocol(name string, getenv func(string) string) (Protocol, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "auto" {
+		return DetectProtocol(getenv), nil
+	}
+
+	p, ok := protocolNames[name]
+	if !ok {
+		return HalfBlocks, fmt.Errorf("unknown image protocol %q (auto, halfblocks, kitty, sixel or iterm2)", name)
+	}
+	return p, nil
+}
+
+// DetectProtocol guesses the protocol of the terminal from the environment.
+// Terminal multiplexers don't pass the images through, they use half-blocks
+func DetectProtocol(getenv func(string) string) Protocol {
+	if getenv("TMUX") != "" || getenv("STY") != "" || getenv("ZELLIJ") != "" {
+		return HalfBlocks
+	}
+
+	term := getenv("TERM")
+	termProgram := getenv("TERM_PROGRAM")
+
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", termProgram == "ghostty":
+		return Kitty
+	case termProgram == "iTerm.app", getenv("LC_TERMINAL") == "iTerm2", termProgram == "WezTerm":
+		return ITerm2
+	case strings.Contains(term, "sixel"), strings.HasPrefix(term, "foot"), strings.HasPrefix(term, "mlterm"), term == "yaft-256color", termProgram == "mintty":
+		return Sixel
+	}
+	return HalfBlocks
+}
+
+// Rows returns the number of terminal rows of img drawn cols columns wide
+func Rows(cols int, img image.Image) int {
+	cellWidth, cellHeight := cellSize()
+	b := img.Bounds()
+	if b.Dx() == 0 {
+		return 0
+	}
+
+	height := float64(cols*cellWidth) * float64(b.Dy()) / float64(b.Dx())
+	return max(1, int(height+float64(cellHeight)-1)/cellHeight)
+}
+
+// Encode returns the escape sequence that draws img in cols x rows cells at
+// the cursor, with a graphics protocol
+func Encode(p Protocol, img image.Image, cols, rows int) string {
+	cellWidth, cellHeight := cellSize()
+
+	// The image is sent at the size it's shown, so big pictures don't
+	// slow down the terminal
+	img = imaging.Fit(img, cols*cellWidth, rows*cellHeight, imaging.Lanczos)
+
+	switch p {
+	case Kitty:
+		return encodeKitty(img, cols, rows)
+	case ITerm2:
+		return encodeITerm2(img, cols, rows)
+	case Sixel:
+		return encodeSixel(img)
+	}
+	return ""
+}
+
+// kittyChunkSize is the max size of the payload of a kitty escape
+const kittyChunkSize = 4096
+
+// kittyDelete removes every image drawn with the kitty protocol
+const kittyDelete = "\x1b_Ga=d,d=A,q=2\x1b\\"
+
+// encodeKitty sends the image as PNG, in chunks. The previous images are
+// deleted first, and the cursor doesn't move
+func encodeKitty(img image.Image, cols, rows int) string {
+	data := encodeBase64PNG(img)
+
+	var b strings.Builder
+	b.WriteString(kittyDelete)
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data[:min(len(data), kittyChunkSize)]
+		data = data[len(chunk):]
+
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+
+		if first {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return b.String()
+}
+
+// encodeITerm2 sends the image as an inline PNG file
+func encodeITerm2(img image.Image, cols, rows int) string {
+	data := encodeBase64PNG(img)
+	return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a", base64.StdEncoding.DecodedLen(len(data)), cols, rows, data)
+}
+
+func encodeBase64PNG(img image.Image) string {
+	var buf bytes.Buffer
+	// Encoding an in-memory image can't fail
+	png.Encode(&buf, img)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// encodeSixel draws the image with the 256 colors of the Plan 9 palette,
+// dithered
+func encodeSixel(img image.Image) string {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	paletted := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1bP0;1q\"1;1;%d;%d", width, height)
+
+	for i, c := range paletted.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	// Every band is 6 rows, the colors of a band are drawn one over the
+	// other returning to the start of the band with $
+	sixels := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		colors := bandColors(paletted, top)
+
+		for _, index := range colors {
+			for x := 0; x < width; x++ {
+				bits := byte(0)
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if paletted.ColorIndexAt(x, top+dy) == index {
+						bits |= 1 << dy
+					}
+				}
+				sixels[x] = 63 + bits
+			}
+
+			fmt.Fprintf(&b, "#%d", index)
+			writeSixelRuns(&b, sixels)
+			b.WriteByte('$')
+		}
+		b.WriteByte('-')
+	}
+
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// bandColors returns the palette indexes used in the band that starts at top
+func bandColors(img *image.Paletted, top int) []uint8 {
+	used := [256]bool{}
+	colors := []uint8{}
+
+	bounds := img.Bounds()
+	for y := top; y < top+6 && y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			index := img.ColorIndexAt(x, y)
+			if !used[index] {
+				used[index] = true
+				colors = append(colors, index)
+			}
+		}
+	}
+	return colors
+}
+
+// writeSixelRuns writes the sixels, repeated ones as !count
+func writeSixelRuns(b *strings.Builder, sixels []byte) {
+	for i := 0; i < len(sixels); {
+		run := 1
+		for i+run < len(sixels) && sixels[i+run] == sixels[i] {
+			run++
+		}
+
+		if run > 3 {
+			fmt.Fprintf(b, "!%d%c", run, sixels[i])
+		} else {
+			b.Write(bytes.Repeat([]byte{sixels[i]}, run))
+		}
+		i += run
+	}
+}
@@ -8,11 +8,21 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/disintegration/imaging"
 	"github.com/lucasb-eyer/go-colorful"
+	"github.com/xyaman/anki-tui/core"
 )
 
 type Model struct {
+	// path identifies the image in the render cache
+	path        string
 	image       image.Image
 	imageString string
+	err         error
+	loading     bool
+
+	// escape draws the image with the graphics protocol, in rows rows.
+	// The view only has a placeholder, a Screen draws it
+	escape string
+	rows   int
 
 	width      int
 	height     int
@@ -53,16 +63,55 @@ func ToString(width int, img image.Image) string {
 	return str.String()
 }
 
-func (m *Model) SetImage(img image.Image) {
+// SetImage shows the image of path, the rendered image is cached by path
+// and width. An empty path isn't cached
+func (m *Model) SetImage(path string, img image.Image) {
+	m.err = nil
+	m.loading = false
+	m.path = path
+	m.escape, m.rows = "", 0
 	if img == nil {
 		m.image = nil
 		m.imageString = lipgloss.Place(40, 20, lipgloss.Center, lipgloss.Center, "no image")
 		return
 	}
-
-	imageString := ToString(m.width, img)
 	m.image = img
-	m.imageString = imageString
+
+	if UsesGraphics() {
+		m.rows = Rows(m.width, img)
+		m.escape = core.App.Images.Render(path, m.width, img, func(width int, img image.Image) string {
+			return Encode(protocol, img, width, Rows(width, img))
+		})
+		m.imageString = placeholder(m.width, m.rows)
+		return
+	}
+
+	m.imageString = core.App.Images.Render(path, m.width, img, ToString)
+}
+
+// Graphics returns the escape sequence that draws the image and its rows,
+// they are empty when it's drawn with half-blocks or isn't loaded
+func (m Model) Graphics() (string, int) {
+	return m.escape, m.rows
+}
+
+// SetLoading shows a placeholder until the image is set
+func (m *Model) SetLoading() {
+	m.image = nil
+	m.err = nil
+	m.loading = true
+	m.escape, m.rows = "", 0
+	m.imageString = lipgloss.Place(40, 20, lipgloss.Center, lipgloss.Center, "loading image...")
+}
+
+// SetError shows a placeholder instead of the image
+func (m *Model) SetError(err error) {
+	m.image = nil
+	m.err = err
+	m.loading = false
+	m.escape, m.rows = "", 0
+	text := lipgloss.NewStyle().Width(36).Align(lipgloss.Center).Render("image unavailable\n\n" + err.Error())
+	m.imageString = lipgloss.Place(40, 20, lipgloss.Center, lipgloss.Center, text)
 }
 
 func (m *Model) SetSize(width, height int) {
@@ -79,7 +128,13 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 	if (m.width != m.prevWidth) || (m.height != m.prevHeight) {
-		m.SetImage(m.image)
+		if m.loading {
+			m.SetLoading()
+		} else if m.err != nil {
+			m.SetError(m.err)
+		} else {
+			m.SetImage(m.path, m.image)
+		}
 		m.prevWidth = m.width
 		m.prevHeight = m.height
 	}
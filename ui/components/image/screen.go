@@ -0,0 +1,107 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// placeholderMarker starts the first line of the space left for a graphics
+// image, so it's found in the view. It only resets text attributes
+const placeholderMarker = "\x1b[23;24;25;27;28;29m"
+
+// placement is a graphics image drawn on the screen. Rows includes an empty
+// row below the image, so the terminal doesn't scroll after drawing it
+type placement struct {
+	row    int
+	col    int
+	rows   int
+	escape string
+}
+
+// Screen draws the graphics images out of the normal rendering, the
+// renderer truncates the lines and would break their escape sequences.
+// The rows of the image are given to the image with tea.SyncScrollArea,
+// so the renderer doesn't draw over them until it's removed
+type Screen struct {
+	placed placement
+}
+
+func NewScreen() *Screen {
+	return &Screen{}
+}
+
+// Reset forgets the drawn image, so it's drawn again on the next Sync.
+// It's needed when the terminal is resized
+func (s *Screen) Reset() {
+	s.placed = placement{}
+}
+
+// Sync draws the image of m where its placeholder is in view, the final
+// view of the program. The image is removed when the placeholder isn't in
+// the view anymore
+func (s *Screen) Sync(view string, m *Model) tea.Cmd {
+	want := placement{}
+	if m != nil && m.escape != "" {
+		if row, col, ok := findPlaceholder(view); ok {
+			want = placement{row: row, col: col, rows: m.rows + 1, escape: m.escape}
+		}
+	}
+
+	if want == s.placed {
+		return nil
+	}
+
+	var cmds []tea.Cmd
+	if s.placed.rows > 0 {
+		cmds = append(cmds, s.clear(s.placed), tea.ClearScrollArea)
+	}
+	if want.rows > 0 {
+		cmds = append(cmds, s.draw(want))
+	}
+	s.placed = want
+
+	return tea.Sequence(cmds...)
+}
+
+// draw gives the rows of p to the image and draws it. The renderer writes
+// the lines from the row above the area, so there is an extra first line
+func (s *Screen) draw(p placement) tea.Cmd {
+	lines := make([]string, p.rows+1)
+	lines[p.rows] = fmt.Sprintf("\x1b7\x1b[%d;%dH%s\x1b8", p.row+1, p.col+1, p.escape)
+	return tea.SyncScrollArea(lines, p.row, p.row+p.rows)
+}
+
+// clear empties the rows of p, kitty images are not removed by text
+func (s *Screen) clear(p placement) tea.Cmd {
+	lines := make([]string, p.rows+1)
+	if protocol == Kitty {
+		lines[p.rows] = kittyDelete
+	}
+	return tea.SyncScrollArea(lines, p.row, p.row+p.rows)
+}
+
+// findPlaceholder returns the row and column of the placeholder in view
+func findPlaceholder(view string) (int, int, bool) {
+	for row, line := range strings.Split(view, "\n") {
+		before, _, ok := strings.Cut(line, placeholderMarker)
+		if ok {
+			return row, lipgloss.Width(before), true
+		}
+	}
+	return 0, 0, false
+}
+
+// placeholder is the space left for a graphics image of cols x rows, with
+// an empty row below
+func placeholder(cols, rows int) string {
+	line := strings.Repeat(" ", cols)
+	lines := make([]string, rows+1)
+	for i := range lines {
+		lines[i] = line
+	}
+	lines[0] = placeholderMarker + line
+	return strings.Join(lines, "\n")
+}
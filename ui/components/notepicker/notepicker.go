@@ -0,0 +1,142 @@
+package notepicker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/xyaman/anki-tui/models"
+)
+
+// SelectedMsg is sent when a note is selected
+type SelectedMsg struct {
+	Note models.Note
+}
+
+// CancelMsg is sent when the picker is closed without selecting a note
+type CancelMsg struct{}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+type item struct {
+	note models.Note
+}
+
+func (i item) Title() string {
+	fields := orderedFields(i.note)
+	if sentence := i.note.GetSentence(); sentence != "" {
+		return cleanValue(sentence)
+	} else if len(fields) > 0 {
+		return fields[0]
+	}
+	return fmt.Sprintf("nid:%d", i.note.NoteID)
+}
+
+func (i item) Description() string {
+	return fmt.Sprintf("nid:%d  %s", i.note.NoteID, strings.Join(orderedFields(i.note), " | "))
+}
+
+func (i item) FilterValue() string {
+	return strings.Join(orderedFields(i.note), " ")
+}
+
+// orderedFields returns the non empty field values of the note, in the
+// note type order
+func orderedFields(note models.Note) []string {
+	type field struct {
+		order int
+		value string
+	}
+
+	fields := []field{}
+	for _, f := range note.Fields {
+		f, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, _ := f["value"].(string)
+		order, _ := f["order"].(float64)
+		if value = cleanValue(value); value != "" {
+			fields = append(fields, field{order: int(order), value: value})
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].order < fields[j].order })
+
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		values[i] = f.value
+	}
+	return values
+}
+
+// cleanValue removes the html tags and newlines of a field value
+func cleanValue(value string) string {
+	value = htmlTag.ReplaceAllString(value, " ")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Model is a list of notes to choose the minning target
+type Model struct {
+	list list.Model
+}
+
+func New(title string, notes []models.Note, width, height int) Model {
+	items := make([]list.Item, len(notes))
+	for i, note := range notes {
+		items[i] = item{note: note}
+	}
+
+	l := list.New(items, list.NewDefaultDelegate(), width, height)
+	l.Title = title
+	l.DisableQuitKeybindings()
+
+	return Model{list: l}
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// Keys are handled by the list while filtering
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+
+		switch msg.String() {
+		case "enter":
+			selected, ok := m.list.SelectedItem().(item)
+			if !ok {
+				return m, nil
+			}
+			return m, func() tea.Msg {
+				return SelectedMsg{Note: selected.note}
+			}
+		case "esc":
+			if m.list.FilterState() == list.FilterApplied {
+				break
+			}
+			return m, func() tea.Msg {
+				return CancelMsg{}
+			}
+		}
+
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m Model) View() string {
+	return m.list.View()
+}
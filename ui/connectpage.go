@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/xyaman/anki-tui/core"
+)
+
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// ConnectedMsg is sent when the connection with AnkiConnect is established
+type ConnectedMsg struct{}
+
+// DisconnectedMsg is sent when AnkiConnect is not reachable anymore
+type DisconnectedMsg struct {
+	Err error
+}
+
+type connectMsg struct {
+	attempt int
+}
+
+type connectResultMsg struct {
+	attempt int
+	err     error
+}
+
+// ConnectPage is shown while AnkiConnect is not reachable. It keeps
+// retrying with an exponential backoff until the connection is established
+type ConnectPage struct {
+	attempt    int
+	connecting bool
+	err        error
+	delay      time.Duration
+	nextRetry  time.Time
+}
+
+func NewConnectPage() ConnectPage {
+	return ConnectPage{delay: minRetryDelay}
+}
+
+func (m ConnectPage) Init() tea.Cmd {
+	return m.connect(0)
+}
+
+func (m ConnectPage) connect(attempt int) tea.Cmd {
+	return func() tea.Msg {
+		return connectMsg{attempt: attempt}
+	}
+}
+
+func tryConnect(attempt int) tea.Cmd {
+	return func() tea.Msg {
+		err := core.App.Connect(context.Background())
+		return connectResultMsg{attempt: attempt, err: err}
+	}
+}
+
+// Reset starts a new connection loop, it's used when the connection is lost
+func (m *ConnectPage) Reset(err error) tea.Cmd {
+	m.err = err
+	m.delay = minRetryDelay
+	m.attempt++
+	return m.connect(m.attempt)
+}
+
+func (m ConnectPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "r" && !m.connecting {
+			m.attempt++
+			return m, m.connect(m.attempt)
+		}
+
+	case connectMsg:
+		// Ignore retries scheduled by an old attempt
+		if msg.attempt != m.attempt || m.connecting {
+			return m, nil
+		}
+		m.connecting = true
+		return m, tryConnect(msg.attempt)
+
+	case connectResultMsg:
+		m.connecting = false
+		if msg.err == nil {
+			m.err = nil
+			m.delay = minRetryDelay
+			return m, func() tea.Msg { return ConnectedMsg{} }
+		}
+
+		m.err = msg.err
+		m.attempt++
+		m.nextRetry = time.Now().Add(m.delay)
+		attempt := m.attempt
+		cmd := tea.Tick(m.delay, func(time.Time) tea.Msg {
+			return connectMsg{attempt: attempt}
+		})
+
+		m.delay *= 2
+		if m.delay > maxRetryDelay {
+			m.delay = maxRetryDelay
+		}
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+func (m ConnectPage) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Waiting for AnkiConnect at %s\n\n", core.App.Config.AnkiConnectUrl)
+
+	if m.connecting {
+		b.WriteString("Connecting...")
+	} else if m.err != nil {
+		retryIn := time.Until(m.nextRetry).Round(time.Second)
+		if retryIn < 0 {
+			retryIn = 0
+		}
+		fmt.Fprintf(&b, "%s\n\nRetrying in %s (press r to retry now)", m.err, retryIn)
+	}
+
+	text := lipgloss.NewStyle().Width(60).Align(lipgloss.Center).Render(b.String())
+	return lipgloss.Place(core.App.AvailableWidth, core.App.AvailableHeight, lipgloss.Center, lipgloss.Center, baseStyle.Padding(1, 2).Render(text))
+}
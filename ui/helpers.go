@@ -1,6 +1,10 @@
 package ui
 
 import (
+	"context"
+	"errors"
+	"image"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/xyaman/anki-tui/core"
@@ -17,8 +21,9 @@ type HideModalMsg struct{}
 type SessionStateMsg string
 
 const (
-	MainPanel  SessionStateMsg = "MainPanel"
-	QueryPanel SessionStateMsg = "QueryPanel"
+	ConnectPanel SessionStateMsg = "ConnectPanel"
+	MainPanel    SessionStateMsg = "MainPanel"
+	QueryPanel   SessionStateMsg = "QueryPanel"
 )
 
 func ShowModal(modal modal.Model) tea.Cmd {
@@ -39,45 +44,125 @@ func GoToPanel(panel SessionStateMsg) tea.Cmd {
 	}
 }
 
+// ErrorMsg converts an error into a message. If AnkiConnect is not reachable
+// the app goes back to the connect page, otherwise the error is logged
+func ErrorMsg(err error) tea.Msg {
+	if core.IsConnectionError(err) {
+		return DisconnectedMsg{Err: err}
+	}
+	return core.InfoLog{Text: err.Error(), Seconds: 3, Type: "error"}
+}
+
+// LogError is like ErrorMsg but returns a command
+func LogError(err error) tea.Cmd {
+	return func() tea.Msg {
+		return ErrorMsg(err)
+	}
+}
+
+// logError logs an error that doesn't come from AnkiConnect, like the
+// errors of media and external sources, so it never disconnects
+func logError(err error) tea.Cmd {
+	if errors.Is(err, models.ErrOpusAudio) {
+		return core.Log(core.InfoLog{Type: "error", Text: "Opus audio can't be played, use mp3, ogg vorbis, wav or flac", Seconds: 3})
+	}
+	return core.Log(core.InfoLog{Type: "error", Text: err.Error(), Seconds: 3})
+}
+
 type FetchNotesMsg struct {
 	notes  []models.Note
 	start  int
 	end    int
 	morphs bool
+	err    error
+
+	// sourceErrs are the errors of the external sources that failed
+	sourceErrs []error
+
+	// search is the external search of the notes, they are appended to
+	// the morph notes when more is true
+	search *core.ExternalSearch
+	more   bool
 }
 
-// Return also end
-func FetchNotes(query string, start, end int, morphs bool, external bool) tea.Cmd {
+// FetchNotes fetches the next n notes of the cursor query
+func FetchNotes(cursor *core.QueryCursor, n int, morphs bool) tea.Cmd {
 	return func() tea.Msg {
-		if !external {
-			res, err := core.App.AnkiConnect.FetchNotesFromQuery(query, start, end)
-			if err != nil {
-				return core.Log(core.InfoLog{Text: err.Error(), Seconds: 3, Type: "error"})
-			}
-
-			for i := range res.Result {
-				res.Result[i].GetFieldsValues(
-					core.App.Config.SentenceFieldName,
-					core.App.Config.MorphFieldName,
-					core.App.Config.AudioFieldName,
-					core.App.Config.ImageFieldName,
-				)
-			}
-
-			return FetchNotesMsg{notes: res.Result, start: start, end: len(res.Result), morphs: morphs}
+		notes, err := cursor.Next(n)
+		if err != nil {
+			return FetchNotesMsg{morphs: morphs, err: err}
 		}
 
-		// External sources
-		results := []models.Note{}
-		for _, source := range core.App.ExternalSources {
-			res, err := source.FetchNotesFromQuery(query, start, end)
-			if err != nil {
-				return core.Log(core.InfoLog{Text: err.Error(), Seconds: 3, Type: "error"})
-			}
+		for i := range notes {
+			notes[i].GetFieldsValues(
+				core.App.Config.SentenceFieldName,
+				core.App.Config.MorphFieldName,
+				core.App.Config.AudioFieldName,
+				core.App.Config.ImageFieldName,
+			)
+		}
+
+		return FetchNotesMsg{notes: notes, end: len(notes), morphs: morphs}
+	}
+}
+
+// ImageMsg is sent when the image ref of a note is decoded. Path is its
+// key in the image cache
+type ImageMsg struct {
+	Ref   string
+	Path  string
+	Image image.Image
+	Err   error
+}
+
+// LoadImage decodes the selected image of the note in the background,
+// with the image workers of the app
+func LoadImage(note *models.Note) tea.Cmd {
+	ref := note.ImageRef()
+	path := core.App.Images.Path(note, core.App.CollectionPath)
+	n := *note
+	return func() tea.Msg {
+		img, err := core.App.Images.Load(n, core.App.CollectionPath)
+		return ImageMsg{Ref: ref, Path: path, Image: img, Err: err}
+	}
+}
+
+// FetchExternalNotes fetches the next page of the external search, with
+// up to n notes of every source. The errors of the sources that fail are
+// logged with the notes of the rest
+func FetchExternalNotes(search *core.ExternalSearch, n int) tea.Cmd {
+	return func() tea.Msg {
+		more := search.Total() > 0
+		notes, errs := search.Next(context.Background(), n)
+		return FetchNotesMsg{notes: notes, end: len(notes), morphs: true, sourceErrs: errs, search: search, more: more}
+	}
+}
+
+// candidatesLimit is the max number of notes listed in the minning target picker
+const candidatesLimit = 50
+
+type MiningCandidatesMsg struct {
+	notes []models.Note
+	err   error
+}
+
+// FetchMiningCandidates fetches the notes of the minning target query
+func FetchMiningCandidates() tea.Cmd {
+	return func() tea.Msg {
+		notes, err := core.App.AnkiConnect.FetchMiningCandidates(core.App.Config.MinningTargetQuery, candidatesLimit)
+		if err != nil {
+			return MiningCandidatesMsg{err: err}
+		}
 
-			results = append(results, res...)
+		for i := range notes {
+			notes[i].GetFieldsValues(
+				core.App.Config.SentenceFieldName,
+				core.App.Config.MorphFieldName,
+				core.App.Config.AudioFieldName,
+				core.App.Config.ImageFieldName,
+			)
 		}
 
-		return FetchNotesMsg{notes: results, start: start, end: len(results), morphs: morphs}
+		return MiningCandidatesMsg{notes: notes}
 	}
 }
@@ -0,0 +1,194 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/xyaman/anki-tui/core"
+	"github.com/xyaman/anki-tui/models"
+)
+
+// mineMedia returns the image and audio field values of the note. The media
+// of external notes is stored in the collection first (storeMediaFile), the
+// media they don't have is left empty
+func mineMedia(note *models.Note) (image string, audio string, err error) {
+	image = note.GetImageValue()
+	audio = note.GetAudioValue()
+
+	if source, ok := note.MediaSource.(core.ExternalSource); ok && !source.Capabilities().Has(core.CanDownload) {
+		return "", "", fmt.Errorf("the media of %s can't be mined", source.DisplayName())
+	}
+
+	caps := core.NoteCapabilities(note)
+	if caps.Has(core.HasImages) {
+		image, err = note.DownloadImage(core.App.AnkiConnect)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	switch {
+	case note.AudioTrim != nil:
+		audio, err = mineTrimmedAudio(note)
+	case caps.Has(core.HasAudio):
+		audio, err = note.DownloadAudio(core.App.AnkiConnect)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return image, audio, nil
+}
+
+// mineTrimmedAudio encodes the trimmed part of the note audio as WAV and
+// stores it in the collection. It returns the audio field value
+func mineTrimmedAudio(note *models.Note) (string, error) {
+	data, err := core.App.Audio.Trim(note, note.AudioTrim.Start, note.AudioTrim.End)
+	if err != nil {
+		return "", fmt.Errorf("trimming audio: %w", err)
+	}
+
+	filename, err := core.App.AnkiConnect.StoreMediaFile(trimmedFilename(note), data)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[sound:%s]", filename), nil
+}
+
+// trimmedFilename returns the filename of the trimmed audio, it has the
+// trim range so different cuts of a clip don't overwrite each other
+func trimmedFilename(note *models.Note) string {
+	name := note.GetFilename()
+	if name == "" {
+		ref := note.AudioRef()
+		if u, err := url.Parse(ref); err == nil && models.IsRemote(ref) {
+			ref = u.Path
+		}
+		name = strings.TrimSuffix(path.Base(ref), path.Ext(ref))
+	}
+
+	trim := note.AudioTrim
+	return fmt.Sprintf("%s_%d-%d.wav", name, trim.Start.Milliseconds(), trim.End.Milliseconds())
+}
+
+// mineTags returns the note tags that are copied to the mined note
+// (except 1T, MT, 0T), and the title of external notes
+func mineTags(note *models.Note) []string {
+	tags := []string{}
+	labels := note.Tags
+	if note.Metadata.Title != "" {
+		labels = append([]string{note.Metadata.Title}, labels...)
+	}
+
+	for _, tag := range labels {
+		if tag != "1T" && tag != "MT" && tag != "0T" {
+			// Anki tags can't contain spaces
+			tags = append(tags, strings.ReplaceAll(tag, " ", "_"))
+		}
+	}
+	return tags
+}
+
+// addImageAndSentenceToCard adds the image and audio of the note to the
+// minning target
+func addImageAndSentenceToCard(note *models.Note, target *models.Note) error {
+	if target == nil {
+		return errors.New("No minning target selected")
+	}
+
+	image, audio, err := mineMedia(note)
+	if err != nil {
+		return err
+	}
+
+	// External sentences can miss one of them, the other is still added
+	if note.IsExternal() {
+		if audio == "" && image == "" {
+			return errors.New("The sentence has no image or audio")
+		}
+	} else if audio == "" {
+		return errors.New("No audio field found, check settings")
+	} else if image == "" {
+		return errors.New("No image field found, check settings")
+	}
+
+	fields := models.Fields{}
+	if audio != "" {
+		fields[core.App.Config.MinningAudioFieldName] = audio
+	}
+	if image != "" {
+		fields[core.App.Config.MinningImageFieldName] = image
+	}
+
+	// Fields and tags are sent in a single request
+	batch := core.App.AnkiConnect.NewBatch()
+	batch.UpdateNoteFields(target.NoteID, fields)
+
+	tags := mineTags(note)
+	if len(tags) > 0 {
+		batch.AddTags([]int{target.NoteID}, strings.Join(tags, " "))
+	}
+
+	return batch.Send()
+}
+
+// createSentenceCard creates a new note from the sentence, using the deck,
+// note type and fields of the config. It returns the new note ID
+func createSentenceCard(note *models.Note) (int, error) {
+	config := core.App.Config
+	if config.NewNoteDeck == "" || config.NewNoteModel == "" {
+		return 0, errors.New("New note deck or note type is empty, check settings")
+	}
+
+	sentence := note.GetSentence()
+	fields := map[string]string{}
+	setField := func(name, value string) {
+		if name != "" && value != "" {
+			fields[name] = value
+		}
+	}
+
+	setField(config.NewNoteSentenceField, sentence)
+	setField(config.NewNoteMorphsField, note.GetMorphs())
+	setField(config.NewNoteTranslationField, note.GetTranslation())
+	if config.NewNoteReadingField != "" {
+		setField(config.NewNoteReadingField, strings.TrimSpace(core.ParseJpSentence(sentence)))
+	}
+
+	newNote := models.NewNote{
+		DeckName:  config.NewNoteDeck,
+		ModelName: config.NewNoteModel,
+		Fields:    fields,
+		Tags:      strings.Fields(config.NewNoteTags),
+		Options: models.NewNoteOptions{
+			AllowDuplicate: false,
+			DuplicateScope: "deck",
+		},
+	}
+	if config.NewNoteSourceTags {
+		newNote.Tags = append(newNote.Tags, mineTags(note)...)
+	}
+
+	// Check duplicates before saving any media
+	canAdd, err := core.App.AnkiConnect.CanAddNotes([]models.NewNote{newNote})
+	if err != nil {
+		return 0, err
+	}
+	if len(canAdd) == 0 || !canAdd[0] {
+		return 0, fmt.Errorf("The note can't be added to %s (duplicate or empty sentence)", config.NewNoteDeck)
+	}
+
+	if config.NewNoteImageField != "" || config.NewNoteAudioField != "" {
+		image, audio, err := mineMedia(note)
+		if err != nil {
+			return 0, err
+		}
+		setField(config.NewNoteImageField, image)
+		setField(config.NewNoteAudioField, audio)
+	}
+
+	return core.App.AnkiConnect.AddNote(newNote)
+}
@@ -1,9 +1,9 @@
 package ui
 
 import (
-	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/help"
@@ -11,39 +11,76 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/gopxl/beep"
-	"github.com/gopxl/beep/generators"
-	"github.com/gopxl/beep/speaker"
 
 	"github.com/xyaman/anki-tui/core"
 	"github.com/xyaman/anki-tui/models"
 	"github.com/xyaman/anki-tui/ui/components/cardviewer"
 	"github.com/xyaman/anki-tui/ui/components/modal"
+	"github.com/xyaman/anki-tui/ui/components/notepicker"
 )
 
 const (
-	mineModal   = "MineModal"
-	deleteModal = "DeleteModal"
+	mineModal    = "MineModal"
+	newNoteModal = "NewNoteModal"
+	deleteModal  = "DeleteModal"
 )
 
+// pageSize is the number of notes requested every time
+const pageSize = 100
+
+// seekStep is the time moved by the seek keys
+const seekStep = 3 * time.Second
+
+// playbackTickMsg refreshes the playback progress while a clip is playing
+// and not paused
+type playbackTickMsg struct{}
+
+func playbackTick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
+		return playbackTickMsg{}
+	})
+}
+
 type QueryPage struct {
 	table table.Model
 
-	// Fetch cursor
-	currentEnd int
+	// Cursor of the minning query, fetching is true while
+	// a page is being requested
+	cursor   *core.QueryCursor
+	fetching bool
 
 	searchNotes     []models.Note
 	morphNotes      []models.Note
 	prevNotesCursor int
 
+	// Search of the external morph notes, nil when the morph notes are
+	// local. fetchingExternal is true while a page is being requested
+	externalSearch   *core.ExternalSearch
+	fetchingExternal bool
+
 	help       help.Model
 	notePage   cardviewer.Model
 	configPage QueryPageConfig
 
 	isConfig bool
 	isNote   bool
-
-	audioCtrl *beep.Ctrl
+	isPicker bool
+
+	// Minning target, it's chosen in the picker and remembered between
+	// mines. pendingMine is the cursor of the note to mine once the
+	// target is chosen, -1 if there is none
+	picker      notepicker.Model
+	mineTarget  *models.Note
+	pendingMine int
+
+	// ticking is true while the playback progress is refreshed
+	ticking bool
+
+	// loadingImages are the image refs being decoded, failedImages the
+	// ones that couldn't be decoded. Failed images are only loaded again
+	// when their note is opened
+	loadingImages map[string]bool
+	failedImages  map[string]bool
 }
 
 func NewQueryPage() QueryPage {
@@ -54,7 +91,7 @@ func NewQueryPage() QueryPage {
 			{Title: "#", Width: 4},
 			{Title: "Sentence", Width: 50},
 			{Title: "Morphs", Width: 20},
-			{Title: "Tags", Width: 50},
+			{Title: "Tags / Title", Width: 50},
 			{Title: "Source", Width: 50},
 		}))
 
@@ -78,7 +115,11 @@ func NewQueryPage() QueryPage {
 		notePage:    cardviewer.New(),
 		configPage:  NewQueryPageConfig(),
 		isConfig:    false,
-		currentEnd:  100,
+		pendingMine: -1,
+		cursor:      core.App.AnkiConnect.NewQueryCursor(core.App.Config.MinningQuery),
+
+		loadingImages: map[string]bool{},
+		failedImages:  map[string]bool{},
 	}
 }
 
@@ -90,7 +131,7 @@ func (m QueryPage) Init() tea.Cmd {
 		return m.configPage.Init()
 	}
 
-	return tea.Batch(FetchNotes(core.App.Config.MinningQuery, 0, 100, false, false), m.configPage.Init())
+	return tea.Batch(FetchNotes(m.cursor, pageSize, false), m.configPage.Init())
 }
 
 func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -112,14 +153,15 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.isConfig = false
 				m.searchNotes = []models.Note{}
-				m.currentEnd = 100
+				m.cursor = core.App.AnkiConnect.NewQueryCursor(core.App.Config.MinningQuery)
 				m.table.SetRows([]table.Row{})
 
 				if core.App.Config.MinningQuery == "" {
 					return m, core.Log(core.InfoLog{Type: "info", Text: "Minning query is empty.", Seconds: 3})
 				}
 
-				cmds := []tea.Cmd{FetchNotes(core.App.Config.MinningQuery, 0, 100, false, false)}
+				m.fetching = true
+				cmds := []tea.Cmd{FetchNotes(m.cursor, pageSize, false)}
 
 				if core.App.Config.SearchQuery == "" {
 					cmds = append(cmds, core.Log(core.InfoLog{Type: "info", Text: "Search query is empty.", Seconds: 3}))
@@ -141,6 +183,59 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	// Handle picker events
+	if m.isPicker {
+		switch msg.(type) {
+		case tea.KeyMsg:
+			var cmd tea.Cmd
+			m.picker, cmd = m.picker.Update(msg)
+			return m, cmd
+		}
+	}
+
+	// Handle trim mode events, the card viewer moves the markers
+	if m.isNote && m.notePage.TrimMode {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "esc":
+				m.notePage.TrimMode = false
+				return m, nil
+
+			// Preview the trimmed clip
+			case "p":
+				start, end := m.notePage.Trim()
+				err := core.App.Audio.PlaySegment(m.notePage.Note, start, end)
+				if err != nil {
+					return m, logError(err)
+				}
+				return m, m.startPlaybackTick()
+
+			case " ":
+				core.App.Audio.TogglePause()
+				return m, m.startPlaybackTick()
+
+			case "enter":
+				start, end := m.notePage.Trim()
+				if end <= start {
+					return m, core.Log(core.InfoLog{Type: "error", Text: "The trim out point must be after the in point", Seconds: 3})
+				}
+
+				note := m.currentNote()
+				if note == nil {
+					return m, nil
+				}
+				note.AudioTrim = m.notePage.TrimRange()
+				m.notePage.TrimMode = false
+				return m, core.Log(core.InfoLog{Type: "info", Text: "The trimmed audio will be used when minning", Seconds: 2})
+			}
+
+			var cmd tea.Cmd
+			m.notePage, cmd = m.notePage.Update(msg)
+			return m, cmd
+		}
+	}
+
 	// Handle notePage & cardview events
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -160,6 +255,7 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			isMorphMode := len(m.morphNotes) > 0
 			if isMorphMode {
 				m.morphNotes = []models.Note{}
+				m.externalSearch = nil
 
 				// Update table
 				m.setNotesToTable(m.searchNotes)
@@ -172,14 +268,13 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// "m" it will look for morphs in the local notes
 		// "e" it will look for morphs in the external notes (BrigadaSOS, ImmersionKit, etc)
 		case "m", "e":
-			isMorphMode := len(m.morphNotes) > 0
-
 			// If we are already in morph mode, we get the current note in the morphs array
 			// If not, we get the current note in the searchNotes array
-			morphs := m.searchNotes[m.table.Cursor()].GetMorphs()
-			if isMorphMode {
-				morphs = m.morphNotes[m.table.Cursor()].GetMorphs()
+			note := m.currentNote()
+			if note == nil {
+				return m, nil
 			}
+			morphs := note.GetMorphs()
 
 			// Dont enter morph mode if there are no morphs in the selected note
 			// if morphs == "" && !isMorphMode
@@ -191,16 +286,22 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// Local search
 			if k == "m" {
+				m.externalSearch = nil
 				query := core.App.Config.SearchQuery + " " + strings.ReplaceAll(morphs, " ", " or ")
 				return m, tea.Batch(
-					FetchNotes(query, 0, 100, true, false),
+					FetchNotes(core.App.AnkiConnect.NewQueryCursor(query), pageSize, true),
 					core.Log(core.InfoLog{Text: "Fetching morphs...", Type: "Info", Seconds: 1}),
 				)
 
 				// External search
 			} else if k == "e" {
+				if len(core.App.ExternalSources) == 0 {
+					return m, core.Log(core.InfoLog{Text: "no external sources enabled, see externalSources in config.yaml", Type: "error", Seconds: 3})
+				}
+				m.externalSearch = core.NewExternalSearch(core.App.ExternalSources, morphs, core.App.Config.ExternalFilter)
+				m.fetchingExternal = true
 				return m, tea.Batch(
-					FetchNotes(morphs, 0, 100, true, true),
+					FetchExternalNotes(m.externalSearch, pageSize),
 					core.Log(core.InfoLog{Text: "[external] Fetching morphs...", Type: "Info", Seconds: 5}),
 				)
 			}
@@ -209,29 +310,107 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, textinput.Blink
 
 		case "p":
-			note := m.searchNotes[m.table.Cursor()]
-			if len(m.morphNotes) > 0 {
-				note = m.morphNotes[m.table.Cursor()]
+			note := m.currentNote()
+			if note == nil {
+				return m, nil
+			}
+			return m, m.playAudio(note)
+
+		// Playback controls of the current clip. Space pages down the
+		// table when the card viewer is closed
+		case " ":
+			if !m.isNote {
+				break
+			}
+			core.App.Audio.TogglePause()
+			return m, m.startPlaybackTick()
+
+		case "r":
+			err := core.App.Audio.Replay()
+			if err != nil {
+				return m, logError(err)
+			}
+			return m, m.startPlaybackTick()
+
+		case "left", "right":
+			offset := seekStep
+			if k == "left" {
+				offset = -seekStep
+			}
+			err := core.App.Audio.Seek(offset)
+			if err != nil {
+				return m, logError(err)
 			}
-			m.playAudio(&note)
 			return m, nil
 
-		case "o":
-			m.showCardViewer()
+		case "[", "]":
+			delta := core.SpeedStep
+			if k == "[" {
+				delta = -delta
+			}
+			core.App.Audio.ChangeSpeed(delta)
+			return m, m.logPlayback()
+
+		case "-", "=", "+":
+			delta := core.VolumeStep
+			if k == "-" {
+				delta = -delta
+			}
+			core.App.Audio.ChangeVolume(delta)
+			return m, m.logPlayback()
+
+		case "L":
+			core.App.Audio.ToggleLoop()
+			return m, m.logPlayback()
 
+		// Mark the part of the audio that is mined
+		case "T":
+			if !m.isNote {
+				return m, core.Log(core.InfoLog{Type: "info", Text: "Open the note to trim its audio", Seconds: 2})
+			}
+			if !m.notePage.StartTrim() {
+				return m, core.Log(core.InfoLog{Type: "info", Text: "The audio waveform is not loaded yet", Seconds: 2})
+			}
+			return m, nil
+
+		// Select the next audio/image when the field has more than one
+		case "A":
+			note := m.currentNote()
+			if note == nil || !note.NextAudio() {
+				return m, nil
+			}
+			return m, tea.Batch(m.playAudio(note), m.notePage.LoadWaveform())
+
+		case "I":
+			note := m.currentNote()
+			if note == nil || !note.NextImage() {
+				return m, nil
+			}
+			if m.isNote {
+				return m, m.showCardViewer()
+			}
 			return m, nil
 
+		case "o":
+			if m.currentNote() == nil {
+				return m, nil
+			}
+			return m, m.showCardViewer()
+
 		case "ctrl+k":
+			if m.currentNote() == nil {
+				return m, nil
+			}
 			err := m.setCardAsKnown()
 			if err != nil {
-				return m, core.Log(core.InfoLog{Type: "error", Text: "Error when setting card as known", Seconds: 3})
+				return m, LogError(fmt.Errorf("Error when setting card as known: %w", err))
 			} else {
 				return m, core.Log(core.InfoLog{Type: "info", Text: fmt.Sprintf("Card set as known (%s)", core.App.Config.KnownTag), Seconds: 2})
 			}
 		case "d":
-			note := m.searchNotes[m.table.Cursor()]
-			if len(m.morphNotes) > 0 {
-				note = m.morphNotes[m.table.Cursor()]
+			note := m.currentNote()
+			if note == nil {
+				return m, nil
 			}
 
 			// Show modal
@@ -242,42 +421,59 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, ShowModal(modal)
 
 		case "ctrl+n":
-			note := m.searchNotes[m.table.Cursor()]
-			if len(m.morphNotes) > 0 {
-				note = m.morphNotes[m.table.Cursor()]
+			if m.currentNote() == nil {
+				return m, nil
+			}
+
+			// The target is chosen before the first mine
+			if m.mineTarget == nil {
+				m.pendingMine = m.table.Cursor()
+				return m, FetchMiningCandidates()
+			}
+			return m, m.showMineModal(m.table.Cursor())
+
+		// Choose another minning target
+		case "t":
+			m.pendingMine = -1
+			return m, FetchMiningCandidates()
+
+		case "n":
+			note := m.currentNote()
+			if note == nil {
+				return m, nil
 			}
 
 			// Show modal
-			sentence := note.GetSentence()
-			modal := modal.New(mineModal, m.table.Cursor(), true)
-			modal.Text = fmt.Sprintf("Add image and sentence to last added card?\n\n%s", sentence)
+			modal := modal.New(newNoteModal, m.table.Cursor(), true)
+			modal.Text = fmt.Sprintf("Create a new card in %s?\n\n%s", core.App.Config.NewNoteDeck, note.GetSentence())
 			modal.OkText = "Yes"
 			modal.CancelText = "No"
 			return m, ShowModal(modal)
 		case "y":
-			sentence := m.searchNotes[m.table.Cursor()].GetSentence()
-			if len(m.morphNotes) > 0 {
-				sentence = m.morphNotes[m.table.Cursor()].GetSentence()
+			note := m.currentNote()
+			if note == nil {
+				return m, nil
 			}
-			clipboard.WriteAll(sentence)
+			clipboard.WriteAll(note.GetSentence())
 
 			// if user moves, update the note. Unless the note is in pitch mode
 			// then pass the movements to the table too
 		case "j", "k":
+			cmds := make([]tea.Cmd, 0)
 			if !m.notePage.PitchMode {
 				var cmd tea.Cmd
-				cmds := make([]tea.Cmd, 0)
 				m.table, cmd = m.table.Update(msg)
-				cmds = append(cmds, cmd)
+				cmds = append(cmds, cmd, m.prefetchAudio(), m.prefetchImages(), m.fetchNextPage())
 
 				if m.isNote {
-					m.showCardViewer()
+					cmds = append(cmds, m.showCardViewer())
 				}
 			}
 
 			var cmd tea.Cmd
 			m.notePage, cmd = m.notePage.Update(msg)
-			return m, cmd
+			cmds = append(cmds, cmd)
+			return m, tea.Batch(cmds...)
 		}
 
 	case tea.WindowSizeMsg:
@@ -288,6 +484,33 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case FetchNotesMsg:
+		// Pages of a previous external search are discarded
+		if msg.search != nil {
+			if msg.search != m.externalSearch {
+				return m, nil
+			}
+			m.fetchingExternal = false
+		}
+
+		if msg.err != nil {
+			if !msg.morphs {
+				m.fetching = false
+			}
+			return m, LogError(msg.err)
+		}
+
+		// The sources that failed are logged, the rest of the notes are shown.
+		// They are not AnkiConnect errors, so they don't disconnect
+		var errCmds []tea.Cmd
+		for _, err := range msg.sourceErrs {
+			errCmds = append(errCmds, logError(err))
+		}
+		if len(errCmds) > 0 {
+			msg.sourceErrs = nil
+			next, cmd := m.Update(msg)
+			return next, tea.Batch(append(errCmds, cmd)...)
+		}
+
 		// Length is 0 when:
 		// 1. First time fetching notes
 		// 2. Config is updated
@@ -295,7 +518,14 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		var notes []models.Note
 
-		if msg.morphs {
+		if msg.more {
+			// Next page of the external search
+			if len(msg.notes) == 0 {
+				return m, nil
+			}
+			m.morphNotes = append(m.morphNotes, msg.notes...)
+			notes = m.morphNotes
+		} else if msg.morphs {
 
 			// This will run when we enter to morph mode
 			if msg.morphs && len(m.morphNotes) == 0 {
@@ -306,6 +536,7 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			notes = m.morphNotes
 			m.table.SetCursor(0)
 		} else {
+			m.fetching = false
 			m.searchNotes = append(m.searchNotes, msg.notes...)
 			notes = m.searchNotes
 		}
@@ -329,20 +560,85 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Update NotePage
 		if m.isNote {
-			m.showCardViewer()
+			return m, tea.Batch(m.showCardViewer(), m.prefetchAudio())
 		}
 
+		return m, tea.Batch(m.prefetchAudio(), m.prefetchImages())
+
+	case ImageMsg:
+		delete(m.loadingImages, msg.Ref)
+		m.failedImages[msg.Ref] = msg.Err != nil
+
+		// The image is cached, errors are shown when the note is opened
+		note := m.notePage.Note
+		if !m.isNote || note == nil || note.ImageRef() != msg.Ref {
+			return m, nil
+		}
+		if msg.Err != nil {
+			m.notePage.Image.SetError(msg.Err)
+			return m, logError(msg.Err)
+		}
+		m.notePage.Image.SetImage(msg.Path, msg.Image)
+		return m, nil
+
+	case playbackTickMsg:
+		// Pausing stops the ticks, resuming starts them again
+		status := core.App.Audio.Status()
+		if !status.Playing || status.Paused {
+			m.ticking = false
+			return m, nil
+		}
+		return m, playbackTick()
+
+	case cardviewer.WaveformMsg:
+		var cmd tea.Cmd
+		m.notePage, cmd = m.notePage.Update(msg)
+		return m, cmd
+
+	case MiningCandidatesMsg:
+		if msg.err != nil {
+			m.pendingMine = -1
+			return m, LogError(msg.err)
+		}
+
+		title := fmt.Sprintf("Minning target (%s)", core.App.Config.MinningTargetQuery)
+		m.picker = notepicker.New(title, msg.notes, core.App.AvailableWidth-4, core.App.AvailableHeight-4)
+		m.isPicker = true
+		return m, nil
+
+	case notepicker.SelectedMsg:
+		m.isPicker = false
+		m.mineTarget = &msg.Note
+
+		cmd := core.Log(core.InfoLog{Type: "info", Text: fmt.Sprintf("Minning target: nid:%d", msg.Note.NoteID), Seconds: 2})
+		if m.pendingMine >= 0 {
+			cursor := m.pendingMine
+			m.pendingMine = -1
+			return m, tea.Batch(cmd, m.showMineModal(cursor))
+		}
+		return m, cmd
+
+	case notepicker.CancelMsg:
+		m.isPicker = false
+		m.pendingMine = -1
 		return m, nil
 
 	case modal.OkMsg:
 		switch msg.ID {
 		case deleteModal:
-			err := core.App.AnkiConnect.DeleteNotes([]int{m.searchNotes[m.table.Cursor()].NoteID})
+			note := m.searchNotes[msg.Cursor]
+			if len(m.morphNotes) > 0 {
+				note = m.morphNotes[msg.Cursor]
+			}
+			err := core.App.AnkiConnect.DeleteNotes([]int{note.NoteID})
 			if err != nil {
-				return m, core.Log(core.InfoLog{Type: "error", Text: fmt.Sprintf("%s", err), Seconds: 3})
+				return m, tea.Batch(
+					LogError(err),
+					HideModal(),
+				)
 			} else {
 				noteCursor := msg.Cursor
-				m.currentEnd -= 1
+				m.cursor.Remove(note.NoteID)
 				if len(m.morphNotes) > 0 {
 					m.morphNotes = append(m.morphNotes[:noteCursor], m.morphNotes[noteCursor+1:]...)
 					m.setNotesToTable(m.morphNotes)
@@ -353,14 +649,16 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// if current cursor is the same as the deleted note
 				// and the notepage is being used, update it
+				var cmd tea.Cmd
 				if m.table.Cursor() == noteCursor && m.isNote {
-					m.showCardViewer()
+					cmd = m.showCardViewer()
 				}
 
 				// return m, core.Log(core.InfoLog{Type: "info", Text: "Note deleted", Seconds: 2})
 				return m, tea.Batch(
 					core.Log(core.InfoLog{Type: "info", Text: "Note deleted", Seconds: 2}),
 					HideModal(),
+					cmd,
 				)
 			}
 
@@ -369,29 +667,63 @@ func (m QueryPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if len(m.morphNotes) > 0 {
 				note = m.morphNotes[msg.Cursor]
 			}
-			err := addImageAndSentenceToLastCard(&note)
+			err := addImageAndSentenceToCard(&note, m.mineTarget)
 			if err != nil {
-				return m, core.Log(core.InfoLog{Type: "error", Text: fmt.Sprintf("%s", err), Seconds: 3})
+				return m, tea.Batch(
+					LogError(err),
+					HideModal(),
+				)
 			} else {
-				return m, core.Log(core.InfoLog{Type: "info", Text: "Image and sentence added to last added card", Seconds: 2})
+				return m, tea.Batch(
+					core.Log(core.InfoLog{Type: "info", Text: fmt.Sprintf("Image and sentence added to nid:%d", m.mineTarget.NoteID), Seconds: 2}),
+					HideModal(),
+				)
+			}
+
+		case newNoteModal:
+			note := m.searchNotes[msg.Cursor]
+			if len(m.morphNotes) > 0 {
+				note = m.morphNotes[msg.Cursor]
+			}
+			noteID, err := createSentenceCard(&note)
+			if err != nil {
+				return m, tea.Batch(LogError(err), HideModal())
 			}
+			return m, tea.Batch(
+				core.Log(core.InfoLog{Type: "info", Text: fmt.Sprintf("Card created in %s (nid:%d)", core.App.Config.NewNoteDeck, noteID), Seconds: 2}),
+				HideModal(),
+			)
 
 		}
 	case modal.CancelMsg:
 		return m, HideModal()
 	}
 
-	// If the table is at the end, fetch more notes
-	// This also works when NotePage is visible
-	if m.table.Cursor() == m.currentEnd-1 {
-		m.currentEnd += 100
-		return m, FetchNotes(core.App.Config.MinningQuery, m.currentEnd, m.currentEnd+100, false, false)
-	}
-
 	// handle table
 	var cmd tea.Cmd
 	m.table, cmd = m.table.Update(msg)
-	return m, cmd
+
+	fetchCmd := m.fetchNextPage()
+	return m, tea.Batch(cmd, fetchCmd)
+}
+
+// fetchNextPage fetches the next page when the table is at the end, the
+// notes of the cursor or the external morph notes.
+// This also works when NotePage is visible
+func (m *QueryPage) fetchNextPage() tea.Cmd {
+	isMorphMode := len(m.morphNotes) > 0
+	if !isMorphMode && !m.fetching && !m.cursor.Done() && m.table.Cursor() == len(m.searchNotes)-1 {
+		m.fetching = true
+		return FetchNotes(m.cursor, pageSize, false)
+	}
+
+	search := m.externalSearch
+	if isMorphMode && search != nil && !m.fetchingExternal && !search.Done() && m.table.Cursor() == len(m.morphNotes)-1 {
+		m.fetchingExternal = true
+		return FetchExternalNotes(search, pageSize)
+	}
+
+	return nil
 }
 
 func (m QueryPage) View() string {
@@ -401,11 +733,16 @@ func (m QueryPage) View() string {
 		return lipgloss.Place(core.App.AvailableWidth, core.App.AvailableHeight, lipgloss.Center, lipgloss.Center, renderConfig)
 	}
 
+	if m.isPicker {
+		renderPicker := baseStyle.Render(m.picker.View())
+		return lipgloss.Place(core.App.AvailableWidth, core.App.AvailableHeight, lipgloss.Center, lipgloss.Center, renderPicker)
+	}
+
 	if m.isNote {
 		return m.notePage.View()
 	}
 
-	topbarinfo := fmt.Sprintf("Query: %s \nTotal: %d\n\n", core.App.Config.MinningQuery, len(m.searchNotes))
+	topbarinfo := fmt.Sprintf("Query: %s \nTotal: %d\n\n", m.cursor.Query(), m.cursor.Total())
 
 	var b strings.Builder
 	b.WriteString(topbarinfo)
@@ -415,31 +752,123 @@ func (m QueryPage) View() string {
 	return lipgloss.JoinVertical(lipgloss.Top, main, m.help.View(cardviewer.HelpKeys))
 }
 
-func (qp *QueryPage) playAudio(note *models.Note) {
+// playAudio plays the note audio and refreshes the progress while it plays
+func (qp *QueryPage) playAudio(note *models.Note) tea.Cmd {
+	err := core.App.Audio.Play(note)
+	if err != nil {
+		return logError(err)
+	}
+	return qp.startPlaybackTick()
+}
+
+// startPlaybackTick starts refreshing the playback progress,
+// unless it's already running
+func (qp *QueryPage) startPlaybackTick() tea.Cmd {
+	if qp.ticking {
+		return nil
+	}
+	qp.ticking = true
+	return playbackTick()
+}
+
+// logPlayback shows the playback settings, the card viewer already shows them
+func (qp *QueryPage) logPlayback() tea.Cmd {
+	if qp.isNote {
+		return nil
+	}
+
+	status := core.App.Audio.Status()
+	text := fmt.Sprintf("Speed x%.1f, volume %+.1f", status.Speed, status.Volume)
+	if status.Loop {
+		text += ", loop"
+	}
+	return core.Log(core.InfoLog{Type: "info", Text: text, Seconds: 2})
+}
+
+// prefetchAudio downloads the audio of the notes next to the cursor,
+// so it's cached when they are played
+func (m *QueryPage) prefetchAudio() tea.Cmd {
+	notes := m.searchNotes
+	if len(m.morphNotes) > 0 {
+		notes = m.morphNotes
+	}
+
+	neighbours := []models.Note{}
+	for _, i := range []int{m.table.Cursor() - 1, m.table.Cursor() + 1, m.table.Cursor() + 2} {
+		if i >= 0 && i < len(notes) {
+			neighbours = append(neighbours, notes[i])
+		}
+	}
+
+	return func() tea.Msg {
+		for i := range neighbours {
+			core.App.Audio.Prefetch(&neighbours[i])
+		}
+		return nil
+	}
+}
+
+// prefetchImages decodes the images of the notes next to the cursor
+func (m *QueryPage) prefetchImages() tea.Cmd {
+	notes := m.searchNotes
+	if len(m.morphNotes) > 0 {
+		notes = m.morphNotes
+	}
 
-	// Stop previous audio
-	if qp.audioCtrl != nil {
-		speaker.Lock()
-		qp.audioCtrl.Paused = true
-		speaker.Unlock()
+	var cmds []tea.Cmd
+	for _, i := range []int{m.table.Cursor(), m.table.Cursor() + 1, m.table.Cursor() + 2, m.table.Cursor() - 1} {
+		if i < 0 || i >= len(notes) || notes[i].ImageRef() == "" || m.failedImages[notes[i].ImageRef()] {
+			continue
+		}
+		if _, ok := core.App.Images.Cached(&notes[i], core.App.CollectionPath); !ok {
+			cmds = append(cmds, m.loadImage(&notes[i]))
+		}
 	}
+	return tea.Batch(cmds...)
+}
 
-	reader, streamer := note.GetAudio(core.App.CollectionPath)
-	if streamer == nil {
-		return
+// loadImage decodes the image of the note, unless it's being decoded
+func (m *QueryPage) loadImage(note *models.Note) tea.Cmd {
+	ref := note.ImageRef()
+	if m.loadingImages[ref] {
+		return nil
 	}
+	m.loadingImages[ref] = true
+	return LoadImage(note)
+}
 
-	speaker.Lock()
-	qp.audioCtrl = &beep.Ctrl{Streamer: streamer}
-	speaker.Unlock()
+// currentNote returns the note selected in the table, it's nil
+// if the table is empty
+func (m *QueryPage) currentNote() *models.Note {
+	notes := m.searchNotes
+	if len(m.morphNotes) > 0 {
+		notes = m.morphNotes
+	}
 
-	// Add a small silence delay
-	silence := generators.Silence(12000)
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(notes) {
+		return nil
+	}
+	return &notes[cursor]
+}
 
-	speaker.Play(beep.Seq(silence, qp.audioCtrl, beep.Callback(func() {
-		streamer.Close()
-		reader.Close()
-	})))
+// showMineModal asks to mine the note at cursor into the minning target
+func (m *QueryPage) showMineModal(cursor int) tea.Cmd {
+	note := m.searchNotes[cursor]
+	if len(m.morphNotes) > 0 {
+		note = m.morphNotes[cursor]
+	}
+
+	target := m.mineTarget.GetSentence()
+	if target == "" {
+		target = fmt.Sprintf("nid:%d", m.mineTarget.NoteID)
+	}
+
+	modal := modal.New(mineModal, cursor, true)
+	modal.Text = fmt.Sprintf("Add image and sentence to %s?\n\n%s", target, note.GetSentence())
+	modal.OkText = "Yes"
+	modal.CancelText = "No"
+	return ShowModal(modal)
 }
 
 func (qp *QueryPage) setNotesToTable(notes []models.Note) {
@@ -451,88 +880,64 @@ func (qp *QueryPage) setNotesToTable(notes []models.Note) {
 			fmt.Sprintf("#%d", i+1),
 			sentence,
 			morphs,
-			strings.Join(note.Tags, ", "),
+			noteLabels(&note),
 			note.GetSource(),
 		}
 	}
 	qp.table.SetRows(rows)
 }
 
-func (m *QueryPage) showCardViewer() {
-	m.isNote = true
+// noteLabels returns the tags of the note, or the title and speaker
+// of an external note
+func noteLabels(note *models.Note) string {
+	if !note.IsExternal() {
+		return strings.Join(note.Tags, ", ")
+	}
 
-	note := m.searchNotes[m.table.Cursor()]
-	if len(m.morphNotes) > 0 {
-		note = m.morphNotes[m.table.Cursor()]
+	labels := note.Metadata.DisplayTitle()
+	if speaker := note.Metadata.Speaker(); speaker != "" {
+		labels += " · " + speaker
+	}
+	return labels
+}
+
+// showCardViewer shows the current note in the card viewer, media errors
+// are returned as a log command
+func (m *QueryPage) showCardViewer() tea.Cmd {
+	note := m.currentNote()
+	if note == nil {
+		m.isNote = false
+		return nil
 	}
+	m.isNote = true
 
 	prevNote := 0
 	if m.notePage.Note != nil {
 		prevNote = m.notePage.Note.NoteID
 	}
-	m.notePage.SetNote(&note)
+	m.notePage.SetNote(note)
 	m.notePage.Image.SetSize(50, 50)
-	image := note.GetImage(core.App.CollectionPath)
-	m.notePage.Image.SetImage(image)
 
-	if core.App.Config.PlayAudioAutomatically && note.NoteID != prevNote {
-		m.playAudio(&note)
-	}
-}
-
-func (qp *QueryPage) setCardAsKnown() error {
-	note := qp.searchNotes[qp.table.Cursor()]
-	if len(qp.morphNotes) > 0 {
-		note = qp.morphNotes[qp.table.Cursor()]
+	// The image is decoded in the background the first time
+	var cmds []tea.Cmd
+	if image, ok := core.App.Images.Cached(note, core.App.CollectionPath); ok || note.ImageRef() == "" {
+		m.notePage.Image.SetImage(core.App.Images.Path(note, core.App.CollectionPath), image)
+	} else {
+		m.notePage.Image.SetLoading()
+		cmds = append(cmds, m.loadImage(note))
 	}
-	note.Tags = append(note.Tags, core.App.Config.KnownTag)
-	return core.App.AnkiConnect.AddTags(note.NoteID, core.App.Config.KnownTag)
-}
-
-// Add image and sentence to last added card
-func addImageAndSentenceToLastCard(note *models.Note) error {
-
-	image := note.GetImageValue()
-	audio := note.GetAudioValue()
 
-	if note.GetSource() != "Anki" {
-		var err error
-		image, err = note.DownloadImage(core.App.CollectionPath)
-		if err != nil {
-			return err
-		}
-		audio, err = note.DownloadAudio(core.App.CollectionPath)
-		if err != nil {
-			return err
-		}
-	}
+	cmds = append(cmds, m.notePage.LoadWaveform(), m.prefetchImages())
 
-	// Get last added card
-	lastAddedCard, err := core.App.AnkiConnect.GetLastAddedCard()
-	if err != nil {
-		return err
-	}
-
-	if audio == "" {
-		return errors.New("No audio field found, check settings")
-	} else if image == "" {
-		return errors.New("No image field found, check settings")
+	if core.App.Config.PlayAudioAutomatically && note.NoteID != prevNote {
+		cmds = append(cmds, m.playAudio(note))
 	}
 
-	err = core.App.AnkiConnect.UpdateNoteFields(lastAddedCard.NoteID, models.Fields{
-		core.App.Config.MinningAudioFieldName: audio,
-		core.App.Config.MinningImageFieldName: image,
-	})
-
-	// Add tcore.App. (except 1T, MT, 0T)
-	for _, tag := range note.Tags {
-		if tag != "1T" && tag != "MT" && tag != "0T" {
-			err = core.App.AnkiConnect.AddTags(lastAddedCard.NoteID, tag)
-			if err != nil {
-				return err
-			}
-		}
-	}
+	return tea.Batch(cmds...)
+}
 
-	return err
+func (qp *QueryPage) setCardAsKnown() error {
+	note := qp.currentNote()
+	note.Tags = append(note.Tags, core.App.Config.KnownTag)
+	return core.App.AnkiConnect.AddTags([]int{note.NoteID}, core.App.Config.KnownTag)
 }
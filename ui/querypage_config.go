@@ -32,7 +32,22 @@ const (
 	KnownTag
 	MinningImageFieldName
 	MinningAudioFieldName
+	MinningTargetQuery
 	PlayAudioAutomatically
+	HideNSFW
+	IncludeTitles
+	ExcludeTitles
+	Speakers
+	NewNoteDeck
+	NewNoteModel
+	NewNoteSentenceField
+	NewNoteReadingField
+	NewNoteMorphsField
+	NewNoteTranslationField
+	NewNoteImageField
+	NewNoteAudioField
+	NewNoteTags
+	NewNoteSourceTags
 )
 
 var labels = []string{
@@ -45,17 +60,35 @@ var labels = []string{
 	"Known Tag               ",
 	"Minning Image Field Name",
 	"Minning Audio Field Name",
+	"Minning Target Query    ",
 	"Play Audio Automatically",
+	"Hide NSFW               ",
+	"Include Titles          ",
+	"Exclude Titles          ",
+	"Speakers                ",
+	"New Note Deck           ",
+	"New Note Type           ",
+	"New Sentence Field      ",
+	"New Reading Field       ",
+	"New Morphs Field        ",
+	"New Translation Field   ",
+	"New Image Field         ",
+	"New Audio Field         ",
+	"New Note Tags           ",
+	"New Note Source Tags    ",
 }
 
+// toggles are the inputs that are switched with enter, "x" is true
+var toggles = map[int]bool{PlayAudioAutomatically: true, HideNSFW: true, NewNoteSourceTags: true}
+
 type QueryPageConfig struct {
 	inputs  []textinput.Model
 	focused int
 }
 
 func NewQueryPageConfig() QueryPageConfig {
-	var inputs = make([]textinput.Model, 10)
-	for i := 0; i < 10; i++ {
+	var inputs = make([]textinput.Model, len(labels))
+	for i := range labels {
 		inputs[i] = textinput.New()
 		inputs[i].Prompt = labels[i] + ": "
 	}
@@ -72,11 +105,36 @@ func NewQueryPageConfig() QueryPageConfig {
 	inputs[KnownTag].SetValue(core.App.Config.KnownTag)
 	inputs[MinningImageFieldName].SetValue(core.App.Config.MinningImageFieldName)
 	inputs[MinningAudioFieldName].SetValue(core.App.Config.MinningAudioFieldName)
+	inputs[MinningTargetQuery].SetValue(core.App.Config.MinningTargetQuery)
 
 	if core.App.Config.PlayAudioAutomatically {
 		inputs[PlayAudioAutomatically].SetValue("x")
 	}
 
+	// The lists of the filter are separated by commas
+	filter := core.App.Config.ExternalFilter
+	if filter.HideNSFW {
+		inputs[HideNSFW].SetValue("x")
+	}
+	inputs[IncludeTitles].SetValue(strings.Join(filter.IncludeTitles, ", "))
+	inputs[ExcludeTitles].SetValue(strings.Join(filter.ExcludeTitles, ", "))
+	inputs[Speakers].SetValue(strings.Join(filter.Speakers, ", "))
+
+	// Notes created from a sentence with "n"
+	config := core.App.Config
+	inputs[NewNoteDeck].SetValue(config.NewNoteDeck)
+	inputs[NewNoteModel].SetValue(config.NewNoteModel)
+	inputs[NewNoteSentenceField].SetValue(config.NewNoteSentenceField)
+	inputs[NewNoteReadingField].SetValue(config.NewNoteReadingField)
+	inputs[NewNoteMorphsField].SetValue(config.NewNoteMorphsField)
+	inputs[NewNoteTranslationField].SetValue(config.NewNoteTranslationField)
+	inputs[NewNoteImageField].SetValue(config.NewNoteImageField)
+	inputs[NewNoteAudioField].SetValue(config.NewNoteAudioField)
+	inputs[NewNoteTags].SetValue(config.NewNoteTags)
+	if config.NewNoteSourceTags {
+		inputs[NewNoteSourceTags].SetValue("x")
+	}
+
 	return QueryPageConfig{
 		inputs: inputs,
 	}
@@ -97,10 +155,40 @@ func (m *QueryPageConfig) Save() error {
 	core.App.Config.MinningImageFieldName = m.inputs[MinningImageFieldName].Value()
 	core.App.Config.MinningAudioFieldName = m.inputs[MinningAudioFieldName].Value()
 	core.App.Config.PlayAudioAutomatically = m.inputs[PlayAudioAutomatically].Value() != ""
+	core.App.Config.MinningTargetQuery = m.inputs[MinningTargetQuery].Value()
+
+	core.App.Config.ExternalFilter = core.ExternalFilter{
+		HideNSFW:      m.inputs[HideNSFW].Value() != "",
+		IncludeTitles: splitList(m.inputs[IncludeTitles].Value()),
+		ExcludeTitles: splitList(m.inputs[ExcludeTitles].Value()),
+		Speakers:      splitList(m.inputs[Speakers].Value()),
+	}
+
+	core.App.Config.NewNoteDeck = m.inputs[NewNoteDeck].Value()
+	core.App.Config.NewNoteModel = m.inputs[NewNoteModel].Value()
+	core.App.Config.NewNoteSentenceField = m.inputs[NewNoteSentenceField].Value()
+	core.App.Config.NewNoteReadingField = m.inputs[NewNoteReadingField].Value()
+	core.App.Config.NewNoteMorphsField = m.inputs[NewNoteMorphsField].Value()
+	core.App.Config.NewNoteTranslationField = m.inputs[NewNoteTranslationField].Value()
+	core.App.Config.NewNoteImageField = m.inputs[NewNoteImageField].Value()
+	core.App.Config.NewNoteAudioField = m.inputs[NewNoteAudioField].Value()
+	core.App.Config.NewNoteTags = m.inputs[NewNoteTags].Value()
+	core.App.Config.NewNoteSourceTags = m.inputs[NewNoteSourceTags].Value() != ""
 
 	return core.App.Config.Save()
 }
 
+// splitList splits a list separated by commas, without empty values
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func (m QueryPageConfig) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds = make([]tea.Cmd, len(m.inputs))
 	var k string
@@ -112,9 +200,10 @@ func (m QueryPageConfig) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		switch msg.String() {
 		case "tab", "ctrl+n", "enter":
-      if k == "enter" && m.focused == len(m.inputs) - 1 {
-        break
-      }
+			// Enter switches the toggles instead of moving
+			if k == "enter" && toggles[m.focused] {
+				break
+			}
 			m.focused++
 			// Because we have the button
 			if m.focused > len(m.inputs) {
@@ -140,7 +229,7 @@ func (m QueryPageConfig) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.inputs[i].TextStyle = focusedStyle
 			m.inputs[i].PromptStyle = focusedStyle
 
-			if labels[i] == "Play Audio Automatically" && k == "enter" {
+			if toggles[i] && k == "enter" {
 				if m.inputs[i].Value() == "" {
 					m.inputs[i].SetValue("x")
 				} else {
@@ -9,6 +9,8 @@ import (
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/xyaman/anki-tui/core"
+	"github.com/xyaman/anki-tui/ui/components/cardviewer"
+	"github.com/xyaman/anki-tui/ui/components/image"
 	"github.com/xyaman/anki-tui/ui/components/modal"
 )
 
@@ -24,29 +26,100 @@ func tick() tea.Msg {
 }
 
 type model struct {
-	state     SessionStateMsg
-	MainPage  tea.Model
-	QueryPage tea.Model
+	state       SessionStateMsg
+	ConnectPage ConnectPage
+	MainPage    tea.Model
+	QueryPage   tea.Model
+
+	// connected is false until the first connection with AnkiConnect,
+	// prevState is the page shown before losing the connection
+	connected bool
+	prevState SessionStateMsg
 
 	showModal bool
 	modal     tea.Model
 
 	logs []core.InfoLog
+
+	// graphics draws the card images with the terminal graphics protocol,
+	// nil when they are drawn with half-blocks. graphicsState is the state
+	// of the last Sync
+	graphics      *image.Screen
+	graphicsState graphicsState
+}
+
+// graphicsState is what decides where the card image is drawn, the view
+// is only searched for the image when it changes
+type graphicsState struct {
+	visible bool
+	escape  string
+	rows    int
+	layout  cardviewer.Layout
 }
 
 func NewProgram() model {
-	return model{
-		state:     MainPanel,
-		MainPage:  NewMainPage(),
-		QueryPage: NewQueryPage(),
+	m := model{
+		state:       ConnectPanel,
+		ConnectPage: NewConnectPage(),
+		MainPage:    NewMainPage(),
+		QueryPage:   NewQueryPage(),
+		prevState:   MainPanel,
 	}
+	if image.UsesGraphics() {
+		m.graphics = image.NewScreen()
+	}
+	return m
 }
 
+// Init starts the connection with AnkiConnect, the pages are initialized
+// once it's established
 func (m model) Init() tea.Cmd {
-	return tea.Batch(m.MainPage.Init(), m.QueryPage.Init(), tick)
+	return tea.Batch(m.ConnectPage.Init(), tick)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	syncCmd := next.syncGraphics(msg)
+	return next, tea.Batch(cmd, syncCmd)
+}
+
+// syncGraphics draws the card image where it's in the view, or removes it
+// when it isn't shown anymore. The view is only rendered when the image or
+// its layout change, or the terminal is resized
+func (m *model) syncGraphics(msg tea.Msg) tea.Cmd {
+	if m.graphics == nil {
+		return nil
+	}
+
+	state := m.currentGraphics()
+	if _, ok := msg.(tea.WindowSizeMsg); ok {
+		m.graphics.Reset()
+	} else if state == m.graphicsState {
+		return nil
+	}
+	m.graphicsState = state
+
+	var img *image.Model
+	if state.visible {
+		page := m.QueryPage.(QueryPage)
+		img = &page.notePage.Image
+	}
+	return m.graphics.Sync(m.View(), img)
+}
+
+// currentGraphics returns the state of the card image, it's only visible
+// in the card viewer of the query page
+func (m model) currentGraphics() graphicsState {
+	page, ok := m.QueryPage.(QueryPage)
+	if !ok || m.state != QueryPanel || m.showModal || page.isConfig || page.isPicker || !page.isNote {
+		return graphicsState{}
+	}
+
+	escape, rows := page.notePage.Image.Graphics()
+	return graphicsState{visible: true, escape: escape, rows: rows, layout: page.notePage.ImageLayout()}
+}
+
+func (m model) update(msg tea.Msg) (model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -68,6 +141,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.state = msg
 		return m, nil
 
+	case connectMsg, connectResultMsg:
+		var cmd tea.Cmd
+		var page tea.Model
+		page, cmd = m.ConnectPage.Update(msg)
+		m.ConnectPage = page.(ConnectPage)
+		return m, cmd
+
+	case ConnectedMsg:
+		m.state = m.prevState
+		if !m.connected {
+			m.connected = true
+			return m, tea.Batch(m.MainPage.Init(), m.QueryPage.Init())
+		}
+		return m, core.Log(core.InfoLog{Type: "info", Text: "Reconnected to AnkiConnect", Seconds: 2})
+
+	case DisconnectedMsg:
+		if m.state == ConnectPanel {
+			return m, nil
+		}
+		m.prevState = m.state
+		m.state = ConnectPanel
+		m.showModal = false
+		return m, m.ConnectPage.Reset(msg.Err)
+
 	case tea.WindowSizeMsg:
 		core.App.Height = msg.Height
 		core.App.Width = msg.Width
@@ -96,7 +193,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.logs = append(m.logs, msg)
 		return m, nil
 
-	case FetchNotesMsg:
+	case FetchNotesMsg, playbackTickMsg, cardviewer.WaveformMsg, ImageMsg:
 		var cmd tea.Cmd
 		m.QueryPage, cmd = m.QueryPage.Update(msg)
 		return m, cmd
@@ -115,7 +212,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	var cmd tea.Cmd
 
-	if m.showModal {
+	if m.state == ConnectPanel {
+		var page tea.Model
+		page, cmd = m.ConnectPage.Update(msg)
+		m.ConnectPage = page.(ConnectPage)
+	} else if m.showModal {
 		m.modal, cmd = m.modal.Update(msg)
 	} else if m.state == MainPanel {
 		m.MainPage, cmd = m.MainPage.Update(msg)
@@ -129,7 +230,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m model) View() string {
 
 	var b strings.Builder
-	if m.showModal {
+	if m.state == ConnectPanel {
+		b.WriteString(m.ConnectPage.View())
+	} else if m.showModal {
 		modalStyle := lipgloss.NewStyle().
 			Padding(1, 0)
 			renderedModal := lipgloss.Place(core.App.AvailableWidth, core.App.AvailableHeight, lipgloss.Center, lipgloss.Center, modalStyle.Render(m.modal.View()))